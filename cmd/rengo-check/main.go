@@ -0,0 +1,64 @@
+package main
+
+import (
+	"RenGO/config"
+	"RenGO/script"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// rengo-check 检查脚本目录下所有 .rgo 文件。它只依赖脚本和配置的解析代码，
+// 不链接 ebiten 和音频库，可以在没有图形环境的 CI 上运行
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("rengo-check", flag.ContinueOnError)
+	configFile := flags.String("config", config.DefaultFile, "game configuration file")
+	dir := flags.String("dir", "", "directory containing .rgo scripts (default from the config file)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rengo-check: %v\n", err)
+		return 2
+	}
+	if *dir == "" {
+		*dir = cfg.ScriptRoot()
+	}
+
+	registry := script.NewRegistry(*dir)
+	modules := flags.Args()
+	for i, m := range modules {
+		modules[i] = registry.ModuleName(m)
+	}
+	if len(modules) == 0 {
+		if modules, err = registry.Modules(); err != nil {
+			fmt.Fprintf(os.Stderr, "rengo-check: %v\n", err)
+			return 2
+		}
+	}
+
+	problems, badFiles := 0, 0
+	for _, module := range modules {
+		errs := registry.Check(module, cfg.Resolve)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			problems += len(errs)
+			badFiles++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) in %d of %d file(s)\n", problems, badFiles, len(modules))
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d file(s) OK\n", len(modules))
+	return 0
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultFile = "game.json"
+
+	defaultEntryScript = "first.rgo"
+	defaultSaveDir     = "./save"
+	defaultFontSize    = 24
+	defaultFontDPI     = 72
+)
+
+// Font 字体文件和字号，Path 相对资源根目录
+type Font struct {
+	Path string  `json:"path"`
+	Size float64 `json:"size"`
+	DPI  float64 `json:"dpi"`
+}
+
+// TextBox 文本框位置、文字颜色和显示速度
+type TextBox struct {
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	MaxWidth  int     `json:"max_width"`
+	TextSpeed float64 `json:"text_speed"` // 每秒显示的字数
+	Color     string  `json:"color"`      // #rrggbb 或 #rrggbbaa
+}
+
+// Slot 立绘位置，X、Y 是立绘底边中点在画面上的坐标
+type Slot struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Game 是 game.json 中的游戏配置，新项目只需修改这个文件
+type Game struct {
+	Title          string          `json:"title"`
+	Width          int             `json:"width"`
+	Height         int             `json:"height"`
+	Layers         int             `json:"layers"`
+	ResourceRoot   string          `json:"resource_root"`
+	Archive        string          `json:"archive,omitempty"` // 打包文件，存在时代替 resource_root 目录
+	ScriptDir      string          `json:"script_dir"`        // 相对资源根目录
+	EntryScript    string          `json:"entry_script"`
+	SaveDir        string          `json:"save_dir"`
+	Font           Font            `json:"font"`
+	ChoiceFont     *Font           `json:"choice_font,omitempty"` // 未设置时与 Font 相同
+	TextBox        TextBox         `json:"text_box"`
+	Slots          map[string]Slot `json:"slots"`           // 具名立绘位置，与默认的 left/center/right 合并
+	CharacterDir   string          `json:"character_dir"`   // 组合立绘定义所在目录，相对资源根目录
+	CharacterLayer int             `json:"character_layer"` // 组合立绘默认所在图层
+	ShaderDir      string          `json:"shader_dir"`      // 脚本可用的 .kage 着色器所在目录，相对资源根目录
+}
+
+// Default 返回与原先硬编码值一致的默认配置
+func Default() *Game {
+	return &Game{
+		Title:        "Visual Novel Engine",
+		Width:        1280,
+		Height:       720,
+		Layers:       5,
+		ResourceRoot: "./resource",
+		ScriptDir:    "script",
+		EntryScript:  defaultEntryScript,
+		SaveDir:      defaultSaveDir,
+		Font: Font{
+			Path: "font/font.ttf",
+			Size: defaultFontSize,
+			DPI:  defaultFontDPI,
+		},
+		TextBox: TextBox{
+			X:         200,
+			Y:         620,
+			MaxWidth:  600,
+			TextSpeed: 30,
+			Color:     "#ffffff",
+		},
+		Slots: map[string]Slot{
+			"left":   {X: 320, Y: 720},
+			"center": {X: 640, Y: 720},
+			"right":  {X: 960, Y: 720},
+		},
+		CharacterDir:   "chara",
+		CharacterLayer: 1,
+		ShaderDir:      "kage",
+	}
+}
+
+// Load 读取配置文件，未写出的字段使用默认值；文件不存在时返回默认配置
+func Load(path string) (*Game, error) {
+	cfg := Default()
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Errors 配置校验中发现的所有问题
+type Errors []string
+
+func (el Errors) Error() string {
+	return "\n  " + strings.Join(el, "\n  ")
+}
+
+func (f *Font) validate(name string, errs *Errors) {
+	if f.Path == "" {
+		*errs = append(*errs, name+".path is required")
+	}
+	if f.Size <= 0 {
+		*errs = append(*errs, fmt.Sprintf("%s.size must be positive, got %v", name, f.Size))
+	}
+	if f.DPI <= 0 {
+		*errs = append(*errs, fmt.Sprintf("%s.dpi must be positive, got %v", name, f.DPI))
+	}
+}
+
+// Validate 检查配置，一次报告所有问题
+func (c *Game) Validate() error {
+	var errs Errors
+	if c.Width <= 0 || c.Height <= 0 {
+		errs = append(errs, fmt.Sprintf("width and height must be positive, got %dx%d", c.Width, c.Height))
+	}
+	if c.Layers <= 0 {
+		errs = append(errs, fmt.Sprintf("layers must be positive, got %d", c.Layers))
+	}
+	if c.ResourceRoot == "" {
+		errs = append(errs, "resource_root is required")
+	}
+	if c.ScriptDir == "" {
+		errs = append(errs, "script_dir is required")
+	}
+	if !strings.HasSuffix(c.EntryScript, ".rgo") {
+		errs = append(errs, fmt.Sprintf("entry_script must be a .rgo file, got %q", c.EntryScript))
+	}
+	if c.SaveDir == "" {
+		errs = append(errs, "save_dir is required")
+	}
+	c.Font.validate("font", &errs)
+	if c.ChoiceFont != nil {
+		c.ChoiceFont.validate("choice_font", &errs)
+	}
+	if c.TextBox.MaxWidth <= 0 {
+		errs = append(errs, fmt.Sprintf("text_box.max_width must be positive, got %d", c.TextBox.MaxWidth))
+	}
+	if c.TextBox.TextSpeed <= 0 {
+		errs = append(errs, fmt.Sprintf("text_box.text_speed must be positive, got %v", c.TextBox.TextSpeed))
+	}
+	if _, err := ParseColor(c.TextBox.Color); err != nil {
+		errs = append(errs, fmt.Sprintf("text_box.color: %v", err))
+	}
+	if c.CharacterLayer < 0 || c.CharacterLayer >= c.Layers {
+		errs = append(errs, fmt.Sprintf("character_layer must be between 0 and %d, got %d", c.Layers-1, c.CharacterLayer))
+	}
+	for name := range c.Slots {
+		if name == "" || strings.Contains(name, ",") {
+			errs = append(errs, fmt.Sprintf("slot name %q must be non-empty and must not contain a comma", name))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Resolve 把资源路径转换为实际路径：绝对路径和已带资源根目录前缀的旧路径保持不变，
+// 其余路径相对资源根目录
+func (c *Game) Resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	clean, root := filepath.Clean(path), filepath.Clean(c.ResourceRoot)
+	if clean == root || strings.HasPrefix(clean, root+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(c.ResourceRoot, path)
+}
+
+// SlotPosition 返回立绘位置的坐标，slot 可以是配置中的名称或 "x,y"
+func (c *Game) SlotPosition(slot string) (float64, float64, error) {
+	if s, ok := c.Slots[slot]; ok {
+		return s.X, s.Y, nil
+	}
+	return ParsePoint(slot)
+}
+
+// ParsePoint 解析 "x,y" 坐标
+func ParsePoint(s string) (float64, float64, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown position %q", s)
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if errX != nil || errY != nil {
+		return 0, 0, fmt.Errorf("invalid position %q, want a slot name or x,y", s)
+	}
+	return x, y, nil
+}
+
+// ParseColor 解析 #rrggbb 或 #rrggbbaa 颜色
+func ParseColor(s string) (color.RGBA, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(hex) != 6 && len(hex) != 8) {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, want #rrggbb or #rrggbbaa", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, want #rrggbb or #rrggbbaa", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// ScriptRoot 返回脚本目录的实际路径
+func (c *Game) ScriptRoot() string {
+	return c.Resolve(c.ScriptDir)
+}
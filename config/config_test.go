@@ -0,0 +1,129 @@
+package config
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), DefaultFile))
+	if err != nil {
+		t.Fatalf("Load of a missing file: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Load of a missing file = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		msgs    []string // 为空时应加载成功
+	}{
+		{"partial file keeps defaults", `{"title": "Test", "width": 800, "height": 600}`, nil},
+		{"custom slot", `{"slots": {"far_left": {"x": 100, "y": 720}}}`, nil},
+		{"zero resolution", `{"width": 0}`, []string{"width and height must be positive, got 0x720"}},
+		{"negative resolution", `{"width": 1280, "height": -1}`, []string{"got 1280x-1"}},
+		{"resolution as string", `{"width": "1280"}`, []string{"failed to parse"}},
+		{"unknown field", `{"widht": 1280}`, []string{`unknown field "widht"`}},
+		{"bad entry script", `{"entry_script": "first.txt"}`, []string{`entry_script must be a .rgo file, got "first.txt"`}},
+		{"bad color", `{"text_box": {"color": "white"}}`, []string{`text_box.color: invalid color "white"`}},
+		{"bad slot name", `{"slots": {"1,2": {"x": 0, "y": 0}}}`, []string{`slot name "1,2"`}},
+		{"several problems", `{"layers": 0, "font": {"path": "", "size": 0}}`, []string{
+			"layers must be positive",
+			"font.path is required",
+			"font.size must be positive",
+			"character_layer must be between 0 and -1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tt.content))
+			if len(tt.msgs) == 0 {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				// 文件中没写出的字段使用默认值
+				if cfg.Layers != 5 || cfg.Font.Path != "font/font.ttf" || len(cfg.Slots) < 3 {
+					t.Errorf("defaults not kept: %+v", cfg)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, msg := range tt.msgs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("error %q does not mention %q", err, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	tests := []struct {
+		s    string
+		x, y float64
+		ok   bool
+	}{
+		{"100,200", 100, 200, true},
+		{" 1.5 , -2 ", 1.5, -2, true},
+		{"100", 0, 0, false},
+		{"100,", 0, 0, false},
+		{"a,b", 0, 0, false},
+		{"1,2,3", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		x, y, err := ParsePoint(tt.s)
+		if (err == nil) != tt.ok || x != tt.x || y != tt.y {
+			t.Errorf("ParsePoint(%q) = %v, %v, %v", tt.s, x, y, err)
+		}
+	}
+
+	cfg := Default()
+	if x, y, err := cfg.SlotPosition("left"); err != nil || x != 320 || y != 720 {
+		t.Errorf("SlotPosition(left) = %v, %v, %v", x, y, err)
+	}
+	if _, _, err := cfg.SlotPosition("middle"); err == nil {
+		t.Error("SlotPosition of an unknown slot name succeeded")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.RGBA
+		ok   bool
+	}{
+		{"#ffffff", color.RGBA{255, 255, 255, 255}, true},
+		{"#FF8000", color.RGBA{255, 128, 0, 255}, true},
+		{"#00000080", color.RGBA{0, 0, 0, 128}, true},
+		{"ffffff", color.RGBA{}, false},
+		{"#fff", color.RGBA{}, false},
+		{"#gggggg", color.RGBA{}, false},
+		{"#+fffff", color.RGBA{}, false},
+		{"", color.RGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v", tt.s, got, err)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package engine
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 打包文件格式：
+//
+//	header  "RGPK" | 版本 u8 | 加密方式 u8 | 保留 u16 | 索引偏移 u64 | 索引长度 u64
+//	entries 每个文件 flate 压缩后再加密，依次排列
+//	index   JSON 格式的文件列表，与文件内容一样压缩、加密
+//
+// 所有整数为小端序。加密只用来防止直接拆包，密钥编译在游戏中，并不能真正保护资源
+const (
+	archiveMagic      = "RGPK"
+	archiveVersion    = 1
+	archiveHeaderSize = 24
+
+	archiveMaxIndexSize = 64 << 20 // 索引解压后的长度上限，文件头中没有记录索引的原始长度
+)
+
+// ArchiveKey 打包文件的密钥，发布时通过 -ldflags "-X RenGO/engine.ArchiveKey=..." 编译进游戏
+var ArchiveKey string
+
+// ArchiveCipher 打包文件内容的加密方式
+type ArchiveCipher uint8
+
+const (
+	CipherNone ArchiveCipher = iota
+	CipherXOR
+	CipherAES
+)
+
+var cipherNames = map[string]ArchiveCipher{"none": CipherNone, "xor": CipherXOR, "aes": CipherAES}
+
+// ParseArchiveCipher 把命令行中的名称转换为加密方式
+func ParseArchiveCipher(name string) (ArchiveCipher, error) {
+	c, ok := cipherNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown cipher %q (want none, xor or aes)", name)
+	}
+	return c, nil
+}
+
+// archiveEntry 索引中的一个文件
+type archiveEntry struct {
+	Name    string    `json:"name"`
+	Offset  int64     `json:"offset"`
+	Size    int64     `json:"size"`     // 压缩加密后的长度
+	RawSize int64     `json:"raw_size"` // 原始长度
+	ModTime time.Time `json:"mod_time"`
+}
+
+// crypt 对一段数据加密或解密，两种方式都是流式异或，加解密是同一个操作。
+// offset 是数据在打包文件中的位置，使每段数据的密钥流不同
+func (c ArchiveCipher) crypt(key []byte, offset int64, data []byte) error {
+	switch c {
+	case CipherNone:
+		return nil
+	case CipherXOR:
+		if len(key) == 0 {
+			return fmt.Errorf("archive is encrypted but no key was given")
+		}
+		for i := range data {
+			data[i] ^= key[(offset+int64(i))%int64(len(key))]
+		}
+		return nil
+	case CipherAES:
+		if len(key) == 0 {
+			return fmt.Errorf("archive is encrypted but no key was given")
+		}
+		sum := sha256.Sum256(key)
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return err
+		}
+		iv := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(offset))
+		cipher.NewCTR(block, iv).XORKeyStream(data, data)
+		return nil
+	}
+	return fmt.Errorf("unknown cipher %d", c)
+}
+
+// Archive 只读的打包文件，实现 fs.FS，可以代替资源目录使用
+type Archive struct {
+	file    *os.File
+	cipher  ArchiveCipher
+	key     []byte
+	size    int64
+	modTime time.Time
+	entries map[string]*archiveEntry
+	dirs    map[string][]string // 目录下的直接子项名称，按名称排序
+}
+
+// OpenArchive 打开打包文件并读取索引
+func OpenArchive(path string, key []byte) (*Archive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	a, err := readArchive(file, key)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read archive %s: %v", path, err)
+	}
+	return a, nil
+}
+
+func readArchive(file *os.File, key []byte) (*Archive, error) {
+	header := make([]byte, archiveHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != archiveMagic {
+		return nil, fmt.Errorf("not a RenGO archive")
+	}
+	if header[4] != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", header[4])
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{
+		file:    file,
+		cipher:  ArchiveCipher(header[5]),
+		key:     key,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		entries: make(map[string]*archiveEntry),
+		dirs:    map[string][]string{".": nil},
+	}
+
+	index := &archiveEntry{
+		Offset:  int64(binary.LittleEndian.Uint64(header[8:])),
+		Size:    int64(binary.LittleEndian.Uint64(header[16:])),
+		RawSize: archiveMaxIndexSize,
+	}
+	raw, err := a.read(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %v", err)
+	}
+	var entries []*archiveEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode index (wrong key?): %v", err)
+	}
+	for _, entry := range entries {
+		if !fs.ValidPath(entry.Name) || entry.Name == "." {
+			return nil, fmt.Errorf("invalid file name %q in index", entry.Name)
+		}
+		a.entries[entry.Name] = entry
+		a.addDir(entry.Name)
+	}
+	for _, children := range a.dirs {
+		sort.Strings(children)
+	}
+	return a, nil
+}
+
+// addDir 把文件登记到所在目录，并逐级登记上层目录
+func (a *Archive) addDir(name string) {
+	for name != "." {
+		dir := path.Dir(name)
+		_, known := a.dirs[dir]
+		a.dirs[dir] = append(a.dirs[dir], path.Base(name))
+		if known {
+			return
+		}
+		name = dir
+	}
+}
+
+// read 读取并解密、解压一个文件的内容
+func (a *Archive) read(entry *archiveEntry) ([]byte, error) {
+	// 损坏的索引可能给出超出文件的范围，先检查再分配内存
+	if entry.Offset < archiveHeaderSize || entry.Size < 0 || entry.Size > a.size-entry.Offset || entry.RawSize < 0 {
+		return nil, fmt.Errorf("entry %q out of range (offset %d, size %d)", entry.Name, entry.Offset, entry.Size)
+	}
+	data := make([]byte, entry.Size)
+	if _, err := a.file.ReadAt(data, entry.Offset); err != nil {
+		return nil, err
+	}
+	if err := a.cipher.crypt(a.key, entry.Offset, data); err != nil {
+		return nil, err
+	}
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	// 解压结果不能超过索引中记录的原始长度，防止损坏或伪造的数据解压出大量内容
+	raw, err := io.ReadAll(io.LimitReader(r, entry.RawSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > entry.RawSize {
+		return nil, fmt.Errorf("entry %q is larger than its recorded size %d", entry.Name, entry.RawSize)
+	}
+	return raw, nil
+}
+
+// ReadFile 实现 fs.ReadFileFS
+func (a *Archive) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := a.read(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Open 实现 fs.FS，文件内容在打开时整体解压到内存
+func (a *Archive) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := a.dirs[name]; ok {
+		entries, err := a.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &archiveDir{info: a.dirInfo(name), entries: entries}, nil
+	}
+	data, err := a.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{Reader: bytes.NewReader(data), info: fileInfo(a.entries[name])}, nil
+}
+
+// ReadDir 实现 fs.ReadDirFS
+func (a *Archive) ReadDir(name string) ([]fs.DirEntry, error) {
+	children, ok := a.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		full := path.Join(name, child)
+		if entry, ok := a.entries[full]; ok {
+			entries = append(entries, fs.FileInfoToDirEntry(fileInfo(entry)))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(a.dirInfo(full)))
+		}
+	}
+	return entries, nil
+}
+
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
+
+func (a *Archive) dirInfo(name string) archiveInfo {
+	return archiveInfo{name: path.Base(name), mode: fs.ModeDir | 0o555, modTime: a.modTime}
+}
+
+func fileInfo(entry *archiveEntry) archiveInfo {
+	return archiveInfo{name: path.Base(entry.Name), size: entry.RawSize, mode: 0o444, modTime: entry.ModTime}
+}
+
+// archiveInfo 实现 fs.FileInfo
+type archiveInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi archiveInfo) Name() string       { return fi.name }
+func (fi archiveInfo) Size() int64        { return fi.size }
+func (fi archiveInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi archiveInfo) ModTime() time.Time { return fi.modTime }
+func (fi archiveInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi archiveInfo) Sys() any           { return nil }
+
+type archiveFile struct {
+	*bytes.Reader
+	info archiveInfo
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *archiveFile) Close() error               { return nil }
+
+type archiveDir struct {
+	info    archiveInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *archiveDir) Close() error               { return nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir 实现 fs.ReadDirFile，n <= 0 时返回剩余的全部子项
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.pos:]
+	if n <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.pos += n
+	return rest[:n], nil
+}
+
+// WriteArchive 把目录下的所有文件打包到 out，返回打包的文件数
+func WriteArchive(out, dir string, c ArchiveCipher, key []byte) (int, error) {
+	if c != CipherNone && len(key) == 0 {
+		return 0, fmt.Errorf("cipher %d needs a key", c)
+	}
+	// 打包文件放在资源目录里时不能把自己也打包进去
+	outAbs, _ := filepath.Abs(out)
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, _ := filepath.Abs(p); abs == outAbs {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			names = append(names, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list files: %v", err)
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer file.Close()
+
+	// 先占位写文件头，索引写完后再回填
+	if _, err := file.Write(make([]byte, archiveHeaderSize)); err != nil {
+		return 0, err
+	}
+	offset := int64(archiveHeaderSize)
+	writeEntry := func(raw []byte) (int64, error) {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(raw); err != nil {
+			return 0, err
+		}
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+		data := buf.Bytes()
+		if err := c.crypt(key, offset, data); err != nil {
+			return 0, err
+		}
+		if _, err := file.Write(data); err != nil {
+			return 0, err
+		}
+		offset += int64(len(data))
+		return int64(len(data)), nil
+	}
+
+	entries := make([]*archiveEntry, 0, len(names))
+	for _, p := range names {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %v", p, err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return 0, err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		entry := &archiveEntry{Name: filepath.ToSlash(rel), Offset: offset, RawSize: int64(len(raw)), ModTime: info.ModTime()}
+		if entry.Size, err = writeEntry(raw); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %v", p, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	index, err := json.Marshal(entries)
+	if err != nil {
+		return 0, err
+	}
+	indexOffset := offset
+	indexSize, err := writeEntry(index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write index: %v", err)
+	}
+
+	header := make([]byte, archiveHeaderSize)
+	copy(header, archiveMagic)
+	header[4] = archiveVersion
+	header[5] = byte(c)
+	binary.LittleEndian.PutUint64(header[8:], uint64(indexOffset))
+	binary.LittleEndian.PutUint64(header[16:], uint64(indexSize))
+	if _, err := file.WriteAt(header, 0); err != nil {
+		return 0, err
+	}
+	return len(entries), file.Close()
+}
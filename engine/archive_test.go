@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var archiveFiles = map[string]string{
+	"game.json":             `{"title": "test"}`,
+	"script/start.rgo":      "*start\nHello.\n",
+	"images/bg/room.png":    strings.Repeat("\x89PNG", 100),
+	"images/chara/a/b.png":  "",
+	"audio/bgm/theme.ogg":   strings.Repeat("OggS", 1000),
+	"kage/sepia.kage":       "//kage:unit pixels\npackage main\n",
+	"script/sub/ending.rgo": "*good\nThe end.\n",
+}
+
+func writeArchiveFixture(t *testing.T, c ArchiveCipher, key []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range archiveFiles {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "data.rgpk")
+	n, err := WriteArchive(out, dir, c, key)
+	if err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	if n != len(archiveFiles) {
+		t.Fatalf("WriteArchive packed %d files, want %d", n, len(archiveFiles))
+	}
+	return out
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	key := []byte("secret key")
+	for _, name := range []string{"none", "xor", "aes"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := ParseArchiveCipher(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := writeArchiveFixture(t, c, key)
+			a, err := OpenArchive(out, key)
+			if err != nil {
+				t.Fatalf("OpenArchive: %v", err)
+			}
+			defer a.Close()
+
+			var names []string
+			for name, want := range archiveFiles {
+				names = append(names, name)
+				got, err := a.ReadFile(name)
+				if err != nil {
+					t.Errorf("ReadFile(%s): %v", name, err)
+					continue
+				}
+				if !bytes.Equal(got, []byte(want)) {
+					t.Errorf("ReadFile(%s) = %q, want %q", name, got, want)
+				}
+			}
+			if err := fstest.TestFS(a, names...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestArchiveWrongKey(t *testing.T) {
+	for _, c := range []ArchiveCipher{CipherXOR, CipherAES} {
+		out := writeArchiveFixture(t, c, []byte("right key"))
+		if a, err := OpenArchive(out, []byte("wrong key")); err == nil {
+			a.Close()
+			t.Errorf("cipher %d: OpenArchive with the wrong key succeeded", c)
+		}
+		if a, err := OpenArchive(out, nil); err == nil {
+			a.Close()
+			t.Errorf("cipher %d: OpenArchive without a key succeeded", c)
+		}
+	}
+}
+
+func TestArchiveTruncated(t *testing.T) {
+	out := writeArchiveFixture(t, CipherNone, nil)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 索引在文件末尾，截断后索引范围超出文件
+	if err := os.WriteFile(out, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := OpenArchive(out, nil); err == nil {
+		a.Close()
+		t.Error("OpenArchive of a truncated archive succeeded")
+	}
+}
+
+func TestArchiveEntryLargerThanRecorded(t *testing.T) {
+	out := writeArchiveFixture(t, CipherNone, nil)
+	a, err := OpenArchive(out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	// 索引记录的原始长度比解压结果小时拒绝读取
+	name := "audio/bgm/theme.ogg"
+	a.entries[name].RawSize = 100
+	if data, err := a.ReadFile(name); err == nil || !strings.Contains(err.Error(), "larger than its recorded size") {
+		t.Errorf("ReadFile(%s) = %d bytes, %v, want a size error", name, len(data), err)
+	}
+	a.entries[name].RawSize = int64(len(archiveFiles[name]))
+	if _, err := a.ReadFile(name); err != nil {
+		t.Errorf("ReadFile(%s) with the exact size: %v", name, err)
+	}
+}
@@ -0,0 +1,419 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/mp3"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	audioSampleRate     = 44100
+	audioBytesPerSample = 4 // 16 位立体声
+)
+
+// AudioChannel 声音通道
+type AudioChannel int
+
+const (
+	ChannelBGM AudioChannel = iota
+	ChannelSE
+	ChannelVoice
+)
+
+// AudioPlayer 是单个正在播放的声音，*audio.Player 直接满足这个接口
+type AudioPlayer interface {
+	Play()
+	Pause()
+	IsPlaying() bool
+	SetVolume(volume float64)
+	Close() error
+}
+
+// LoopPoints BGM 的循环区间，单位为秒，End 为 0 表示循环到文件结尾
+type LoopPoints struct {
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+}
+
+// AudioOutput 负责把声音文件变成可播放的 AudioPlayer
+type AudioOutput interface {
+	NewPlayer(path string, loop bool, points LoopPoints) (AudioPlayer, error)
+}
+
+// ebitenAudioOutput 使用 ebiten 的音频上下文解码并播放 OGG/WAV/MP3
+type ebitenAudioOutput struct {
+	context *audio.Context
+	res     *ResourceManager
+}
+
+func newEbitenAudioOutput(res *ResourceManager) *ebitenAudioOutput {
+	context := audio.CurrentContext()
+	if context == nil {
+		context = audio.NewContext(audioSampleRate)
+	}
+	return &ebitenAudioOutput{context: context, res: res}
+}
+
+func (o *ebitenAudioOutput) NewPlayer(path string, loop bool, points LoopPoints) (AudioPlayer, error) {
+	raw, err := o.res.Data(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %v", err)
+	}
+	stream, length, err := decodeAudio(path, bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio file %s: %v", path, err)
+	}
+
+	var src io.Reader = stream
+	if loop {
+		start := secondsToBytes(points.Start)
+		end := length
+		if points.End > 0 && secondsToBytes(points.End) < length {
+			end = secondsToBytes(points.End)
+		}
+		if start >= end {
+			return nil, fmt.Errorf("invalid loop points %.2fs-%.2fs for %s", points.Start, points.End, path)
+		}
+		src = audio.NewInfiniteLoopWithIntro(stream, start, end-start)
+	}
+
+	player, err := o.context.NewPlayer(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio player: %v", err)
+	}
+	return player, nil
+}
+
+// decodeAudio 按扩展名选择解码器，统一重采样到 audioSampleRate
+func decodeAudio(path string, src io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg":
+		s, err := vorbis.DecodeWithSampleRate(audioSampleRate, src)
+		if err != nil {
+			return nil, 0, err
+		}
+		return s, s.Length(), nil
+	case ".wav":
+		s, err := wav.DecodeWithSampleRate(audioSampleRate, src)
+		if err != nil {
+			return nil, 0, err
+		}
+		return s, s.Length(), nil
+	case ".mp3":
+		s, err := mp3.DecodeWithSampleRate(audioSampleRate, src)
+		if err != nil {
+			return nil, 0, err
+		}
+		return s, s.Length(), nil
+	}
+	return nil, 0, fmt.Errorf("unsupported audio format %q", filepath.Ext(path))
+}
+
+// secondsToBytes 把秒数换算成解码后流中的字节偏移，按采样对齐
+func secondsToBytes(seconds float64) int64 {
+	return int64(seconds*audioSampleRate) * audioBytesPerSample
+}
+
+// nullAudioOutput 不读取文件也不发声，用于无窗口模式和测试
+type nullAudioOutput struct{}
+
+func (nullAudioOutput) NewPlayer(path string, loop bool, points LoopPoints) (AudioPlayer, error) {
+	return &nullPlayer{}, nil
+}
+
+type nullPlayer struct {
+	playing bool
+	volume  float64
+}
+
+func (p *nullPlayer) Play()                    { p.playing = true }
+func (p *nullPlayer) Pause()                   { p.playing = false }
+func (p *nullPlayer) IsPlaying() bool          { return p.playing }
+func (p *nullPlayer) SetVolume(volume float64) { p.volume = volume }
+func (p *nullPlayer) Close() error             { p.playing = false; return nil }
+
+// audioTrack 一个正在播放的声音及其淡入淡出状态
+type audioTrack struct {
+	player  AudioPlayer
+	channel AudioChannel
+	path    string
+	volume  float64 // 脚本指定的音量
+	fade    float64 // 淡入淡出系数 0~1
+
+	fadeFrom, fadeTo  float64
+	fadeTime, fadeDur float64
+	stopAfterFade     bool
+}
+
+func (t *audioTrack) startFade(to, duration float64, stop bool) {
+	t.fadeFrom, t.fadeTo = t.fade, to
+	t.fadeTime, t.fadeDur = 0, duration
+	t.stopAfterFade = stop
+	if duration <= 0 {
+		t.fade = to
+		t.fadeDur = 0
+	}
+}
+
+// update 推进淡入淡出，返回音轨是否已经结束
+func (t *audioTrack) update(dt float64) bool {
+	if t.fadeDur > 0 {
+		t.fadeTime += dt
+		progress := t.fadeTime / t.fadeDur
+		if progress >= 1 {
+			progress = 1
+			t.fadeDur = 0
+		}
+		t.fade = t.fadeFrom + (t.fadeTo-t.fadeFrom)*progress
+	}
+	if t.stopAfterFade && t.fadeDur == 0 {
+		return true
+	}
+	return !t.player.IsPlaying()
+}
+
+// BGMState 记录当前 BGM，用于存档
+type BGMState struct {
+	Path   string     `json:"path"`
+	Volume float64    `json:"volume"`
+	Loop   LoopPoints `json:"loop"`
+}
+
+// AudioManager 管理 BGM、音效和语音三个通道
+type AudioManager struct {
+	output  AudioOutput
+	bgm     *audioTrack
+	bgmLoop LoopPoints
+	voice   *audioTrack
+	se      []*audioTrack
+	fading  []*audioTrack // 正在淡出、已不属于任何通道的音轨
+
+	pendingVoice string // 已开始但还没有显示对应文本行的语音，语音提前播完时也保留
+
+	master   float64
+	channels [3]float64 // 按 AudioChannel 下标的通道音量
+}
+
+func NewAudioManager(output AudioOutput) *AudioManager {
+	return &AudioManager{output: output, master: 1, channels: [3]float64{1, 1, 1}}
+}
+
+// SetMasterVolume 设置总音量并立即应用到所有音轨
+func (am *AudioManager) SetMasterVolume(v float64) {
+	am.master = v
+	am.applyAll()
+}
+
+// SetChannelVolume 设置通道音量并立即应用到该通道的音轨
+func (am *AudioManager) SetChannelVolume(channel AudioChannel, v float64) {
+	am.channels[channel] = v
+	am.applyAll()
+}
+
+func (am *AudioManager) applyAll() {
+	if am.bgm != nil {
+		am.apply(am.bgm)
+	}
+	if am.voice != nil {
+		am.apply(am.voice)
+	}
+	for _, t := range am.se {
+		am.apply(t)
+	}
+	for _, t := range am.fading {
+		am.apply(t)
+	}
+}
+
+func (am *AudioManager) newTrack(channel AudioChannel, path string, volume float64, loop bool, points LoopPoints) (*audioTrack, error) {
+	player, err := am.output.NewPlayer(path, loop, points)
+	if err != nil {
+		return nil, err
+	}
+	t := &audioTrack{player: player, channel: channel, path: path, volume: volume, fade: 1}
+	am.apply(t)
+	return t, nil
+}
+
+// apply 把音轨的实际音量设置到播放器
+func (am *AudioManager) apply(t *audioTrack) {
+	t.player.SetVolume(t.volume * t.fade * am.channels[t.channel] * am.master)
+}
+
+// fadeOut 让音轨淡出后关闭，fade 为 0 时立即关闭
+func (am *AudioManager) fadeOut(t *audioTrack, fade float64) {
+	if t == nil {
+		return
+	}
+	if fade <= 0 {
+		t.player.Close()
+		return
+	}
+	t.startFade(0, fade, true)
+	am.fading = append(am.fading, t)
+}
+
+// PlayBGM 播放循环 BGM，同一首曲子正在播放时只调整音量；fade 秒内与旧曲交叉淡入淡出
+func (am *AudioManager) PlayBGM(path string, volume, fade float64, points LoopPoints) error {
+	if am.bgm != nil && am.bgm.path == path && am.bgmLoop == points {
+		am.bgm.volume = volume
+		am.apply(am.bgm)
+		return nil
+	}
+	t, err := am.newTrack(ChannelBGM, path, volume, true, points)
+	if err != nil {
+		return err
+	}
+	am.fadeOut(am.bgm, fade)
+	if fade > 0 {
+		t.fade = 0
+		t.startFade(1, fade, false)
+		am.apply(t)
+	}
+	t.player.Play()
+	am.bgm = t
+	am.bgmLoop = points
+	return nil
+}
+
+// StopBGM 停止 BGM，fade 为淡出秒数
+func (am *AudioManager) StopBGM(fade float64) {
+	am.fadeOut(am.bgm, fade)
+	am.bgm = nil
+	am.bgmLoop = LoopPoints{}
+}
+
+// BGM 返回当前 BGM 的状态，没有播放时返回 nil
+func (am *AudioManager) BGM() *BGMState {
+	if am.bgm == nil {
+		return nil
+	}
+	return &BGMState{Path: am.bgm.path, Volume: am.bgm.volume, Loop: am.bgmLoop}
+}
+
+// PlaySE 播放音效，多个音效可以同时播放
+func (am *AudioManager) PlaySE(path string, volume float64) error {
+	t, err := am.newTrack(ChannelSE, path, volume, false, LoopPoints{})
+	if err != nil {
+		return err
+	}
+	t.player.Play()
+	am.se = append(am.se, t)
+	return nil
+}
+
+func (am *AudioManager) StopSE() {
+	for _, t := range am.se {
+		t.player.Close()
+	}
+	am.se = nil
+}
+
+// PlayVoice 播放语音，同时只有一条语音，会打断上一条
+func (am *AudioManager) PlayVoice(path string, volume float64) error {
+	t, err := am.newTrack(ChannelVoice, path, volume, false, LoopPoints{})
+	if err != nil {
+		return err
+	}
+	am.StopVoice()
+	t.player.Play()
+	am.voice = t
+	am.pendingVoice = path
+	return nil
+}
+
+func (am *AudioManager) StopVoice() {
+	am.releaseVoice()
+	am.pendingVoice = ""
+}
+
+// releaseVoice 关闭语音音轨，不影响还没有显示的文本行与语音的对应
+func (am *AudioManager) releaseVoice() {
+	if am.voice != nil {
+		am.voice.player.Close()
+		am.voice = nil
+	}
+}
+
+// VoicePlaying 语音是否还在播放
+func (am *AudioManager) VoicePlaying() bool {
+	return am.voice != nil && am.voice.player.IsPlaying()
+}
+
+// Voice 返回当前语音文件路径
+func (am *AudioManager) Voice() string {
+	if am.voice == nil {
+		return ""
+	}
+	return am.voice.path
+}
+
+// ReplayVoice 重放历史中的语音，显示下一行文本时停止
+func (am *AudioManager) ReplayVoice(path string) {
+	if err := am.PlayVoice(path, 1); err != nil {
+		log.Printf("Failed to replay voice: %v", err)
+		return
+	}
+	am.pendingVoice = ""
+}
+
+// onTextLine 在显示新文本行时调用，返回属于这一行的语音：
+// 语音属于紧随其后的那一行，再往后显示下一行时停止上一行的语音
+func (am *AudioManager) onTextLine() string {
+	if voice := am.pendingVoice; voice != "" {
+		am.pendingVoice = ""
+		return voice
+	}
+	am.StopVoice()
+	return ""
+}
+
+// Update 推进淡入淡出并回收已经播放完的音轨，dt 为秒
+func (am *AudioManager) Update(dt float64) {
+	if am.bgm != nil {
+		am.bgm.update(dt)
+		am.apply(am.bgm)
+	}
+	if am.voice != nil && am.voice.update(dt) {
+		am.releaseVoice() // 语音比文本行先显示完时仍要记入历史
+	}
+
+	alive := am.se[:0]
+	for _, t := range am.se {
+		if t.update(dt) {
+			t.player.Close()
+			continue
+		}
+		alive = append(alive, t)
+	}
+	am.se = alive
+
+	fading := am.fading[:0]
+	for _, t := range am.fading {
+		if t.update(dt) {
+			t.player.Close()
+			continue
+		}
+		am.apply(t)
+		fading = append(fading, t)
+	}
+	am.fading = fading
+}
+
+// StopAll 立即停止所有通道
+func (am *AudioManager) StopAll() {
+	am.StopBGM(0)
+	am.StopSE()
+	am.StopVoice()
+	for _, t := range am.fading {
+		t.player.Close()
+	}
+	am.fading = nil
+}
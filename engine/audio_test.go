@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func playerOf(t *audioTrack) *nullPlayer {
+	return t.player.(*nullPlayer)
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAudioChannelVolume(t *testing.T) {
+	am := NewAudioManager(nullAudioOutput{})
+	if err := am.PlayBGM("bgm/theme.ogg", 0.8, 0, LoopPoints{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.PlaySE("se/door.wav", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.PlayVoice("voice/alice_001.ogg", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	bgm, se, voice := playerOf(am.bgm), playerOf(am.se[0]), playerOf(am.voice)
+
+	// 通道音量只影响该通道的音轨，总音量影响所有音轨
+	am.SetChannelVolume(ChannelSE, 0.5)
+	am.SetMasterVolume(0.5)
+	tests := []struct {
+		name   string
+		player *nullPlayer
+		want   float64
+	}{
+		{"bgm", bgm, 0.8 * 0.5},
+		{"se", se, 1 * 0.5 * 0.5},
+		{"voice", voice, 0.5 * 0.5},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.player.volume, tt.want) {
+			t.Errorf("%s volume %v, want %v", tt.name, tt.player.volume, tt.want)
+		}
+		if !tt.player.playing {
+			t.Errorf("%s is not playing", tt.name)
+		}
+	}
+
+	// 新语音打断上一条语音，不影响其他通道
+	if err := am.PlayVoice("voice/alice_002.ogg", 1); err != nil {
+		t.Fatal(err)
+	}
+	if voice.playing {
+		t.Error("previous voice still playing")
+	}
+	if !bgm.playing || !se.playing {
+		t.Error("playing a voice stopped another channel")
+	}
+	if got := am.Voice(); got != "voice/alice_002.ogg" {
+		t.Errorf("voice %q, want voice/alice_002.ogg", got)
+	}
+}
+
+func TestBGMCrossfade(t *testing.T) {
+	am := NewAudioManager(nullAudioOutput{})
+	if err := am.PlayBGM("bgm/day.ogg", 1, 0, LoopPoints{}); err != nil {
+		t.Fatal(err)
+	}
+	day := playerOf(am.bgm)
+
+	// 同一首曲子只调整音量
+	if err := am.PlayBGM("bgm/day.ogg", 0.6, 2, LoopPoints{}); err != nil {
+		t.Fatal(err)
+	}
+	if playerOf(am.bgm) != day || len(am.fading) != 0 || !closeTo(day.volume, 0.6) {
+		t.Fatalf("replaying the same BGM restarted it or did not change its volume (%v)", day.volume)
+	}
+
+	if err := am.PlayBGM("bgm/night.ogg", 1, 2, LoopPoints{}); err != nil {
+		t.Fatal(err)
+	}
+	night := playerOf(am.bgm)
+	if night.volume != 0 || len(am.fading) != 1 {
+		t.Fatalf("new BGM starts at volume %v with %d fading tracks", night.volume, len(am.fading))
+	}
+
+	am.Update(1)
+	if !closeTo(day.volume, 0.3) || !closeTo(night.volume, 0.5) {
+		t.Errorf("halfway through the crossfade: old %v, new %v", day.volume, night.volume)
+	}
+
+	am.Update(1.5)
+	if day.playing || len(am.fading) != 0 {
+		t.Error("old BGM not closed after the crossfade")
+	}
+	if !night.playing || !closeTo(night.volume, 1) {
+		t.Errorf("new BGM playing %v at volume %v after the crossfade", night.playing, night.volume)
+	}
+	if got := am.BGM(); got == nil || got.Path != "bgm/night.ogg" {
+		t.Errorf("BGM state %+v", got)
+	}
+
+	am.StopBGM(1)
+	am.Update(1)
+	if night.playing || am.BGM() != nil || len(am.fading) != 0 {
+		t.Error("BGM still playing after fading out")
+	}
+}
+
+func TestSERecycling(t *testing.T) {
+	am := NewAudioManager(nullAudioOutput{})
+	for _, path := range []string{"se/a.wav", "se/b.wav", "se/c.wav"} {
+		if err := am.PlaySE(path, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c := playerOf(am.se[0]), playerOf(am.se[1]), playerOf(am.se[2])
+
+	// 播放完的音效在 Update 中回收，其余的继续播放
+	b.playing = false
+	am.Update(1.0 / 60)
+	if len(am.se) != 2 || am.se[0].path != "se/a.wav" || am.se[1].path != "se/c.wav" {
+		t.Fatalf("%d sound effects left after one finished", len(am.se))
+	}
+	if !a.playing || !c.playing {
+		t.Error("recycling stopped a sound effect that was still playing")
+	}
+
+	am.StopSE()
+	if len(am.se) != 0 || a.playing || c.playing {
+		t.Error("StopSE left sound effects playing")
+	}
+}
+
+func TestVoiceFinishedBeforeLine(t *testing.T) {
+	am := NewAudioManager(nullAudioOutput{})
+	if err := am.PlayVoice("voice/alice_001.ogg", 1); err != nil {
+		t.Fatal(err)
+	}
+	voice := playerOf(am.voice)
+
+	// 语音在文本行显示之前就播放完，仍然属于这一行
+	voice.playing = false
+	am.Update(1.0 / 60)
+	if am.voice != nil || am.VoicePlaying() {
+		t.Fatal("finished voice track was not released")
+	}
+	if got := am.onTextLine(); got != "voice/alice_001.ogg" {
+		t.Errorf("voice of the next line %q, want voice/alice_001.ogg", got)
+	}
+	if got := am.onTextLine(); got != "" {
+		t.Errorf("voice carried over to a second line: %q", got)
+	}
+
+	// 重放的语音不记入下一行
+	am.ReplayVoice("voice/alice_001.ogg")
+	if got := am.onTextLine(); got != "" {
+		t.Errorf("replayed voice attached to the next line: %q", got)
+	}
+	if am.VoicePlaying() {
+		t.Error("replayed voice still playing after the next line")
+	}
+}
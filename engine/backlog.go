@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+	"image/color"
+	"strings"
+)
+
+const (
+	defaultBacklogLimit = 200 // 最多保留的历史条数
+	backlogMargin       = 40
+	backlogEntryGap     = 16
+)
+
+// BacklogEntry 一条已显示过的文本
+type BacklogEntry struct {
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
+	Voice   string `json:"voice,omitempty"`
+}
+
+// splitSpeaker 拆出 "名字: 台词" 开头的人名，与 TextDisplay 的排版规则一致
+func splitSpeaker(s string) (speaker, line string) {
+	first, rest, _ := strings.Cut(s, " ")
+	if len(first) > 1 && strings.HasSuffix(first, ":") {
+		return strings.TrimSuffix(first, ":"), rest
+	}
+	return "", s
+}
+
+// Backlog 记录所有显示过的文本，并提供可滚动的历史界面
+type Backlog struct {
+	Entries []BacklogEntry
+	Limit   int
+	Font    font.Face
+	IsOpen  bool
+
+	engine        *Engine
+	width, height int
+	count         int // 累计记录过的条数，超出 Limit 被丢弃的也计算在内
+	scroll        int // 从最新一条往上滚动的条数
+	rects         []backlogRect
+	hovered       int
+}
+
+// backlogRect 界面上一条历史的位置，用于点击重放语音
+type backlogRect struct {
+	index int
+	rect  Rect
+}
+
+func NewBacklog(engine *Engine, width, height int) *Backlog {
+	return &Backlog{
+		Limit:   defaultBacklogLimit,
+		engine:  engine,
+		width:   width,
+		height:  height,
+		hovered: -1,
+	}
+}
+
+// Add 记录一行文本，voice 为这一行的语音文件
+func (b *Backlog) Add(s, voice string) {
+	speaker, line := splitSpeaker(s)
+	b.Entries = append(b.Entries, BacklogEntry{Speaker: speaker, Text: line, Voice: voice})
+	b.count++
+	if b.Limit > 0 && len(b.Entries) > b.Limit {
+		b.Entries = append([]BacklogEntry(nil), b.Entries[len(b.Entries)-b.Limit:]...)
+	}
+}
+
+// Restore 用存档中的历史替换当前记录
+func (b *Backlog) Restore(entries []BacklogEntry) {
+	b.Entries = append([]BacklogEntry(nil), entries...)
+	b.count = len(b.Entries)
+	b.Close()
+}
+
+// Count 返回累计记录过的条数，用作回退点的位置
+func (b *Backlog) Count() int {
+	return b.count
+}
+
+// Truncate 删除位置 pos 之后记录的条目
+func (b *Backlog) Truncate(pos int) {
+	remove := b.count - pos
+	if remove <= 0 {
+		return
+	}
+	if remove > len(b.Entries) {
+		remove = len(b.Entries)
+	}
+	b.Entries = b.Entries[:len(b.Entries)-remove]
+	b.count = pos
+}
+
+func (b *Backlog) Open() {
+	if len(b.Entries) == 0 {
+		return
+	}
+	b.IsOpen = true
+	b.scroll = 0
+	b.hovered = -1
+}
+
+func (b *Backlog) Close() {
+	b.IsOpen = false
+	b.scroll = 0
+	b.rects = nil
+}
+
+// Update 处理历史界面的输入：滚轮或上下键翻阅，滚到最底部继续向下或右键、返回键关闭，点击条目重放语音
+func (b *Backlog) Update() {
+	input := b.engine.Input
+	_, wheel := ebiten.Wheel()
+	switch {
+	case wheel > 0 || input.JustPressed(ActionUp):
+		if b.scroll < len(b.Entries)-1 {
+			b.scroll++
+		}
+	case wheel < 0 || input.JustPressed(ActionDown):
+		if b.scroll == 0 {
+			b.Close()
+			return
+		}
+		b.scroll--
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) || input.JustPressed(ActionCancel) || input.JustPressed(ActionBacklog) {
+		b.Close()
+		return
+	}
+
+	x, y := ebiten.CursorPosition()
+	b.hovered = -1
+	for _, r := range b.rects {
+		if x >= r.rect.X && x <= r.rect.X+r.rect.Width && y >= r.rect.Y && y <= r.rect.Y+r.rect.Height {
+			b.hovered = r.index
+			break
+		}
+	}
+	if b.hovered >= 0 && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		b.replay(b.hovered)
+	}
+	// 键盘和手柄重放最下面一条可见历史的语音
+	if input.JustPressed(ActionConfirm) && b.scroll < len(b.Entries) {
+		b.replay(len(b.Entries) - 1 - b.scroll)
+	}
+}
+
+// replay 重放一条历史的语音
+func (b *Backlog) replay(index int) {
+	entry := b.Entries[index]
+	if entry.Voice == "" {
+		return
+	}
+	b.engine.Audio.ReplayVoice(entry.Voice)
+}
+
+// Draw 从最新一条开始自下而上绘制历史
+func (b *Backlog) Draw(screen *ebiten.Image) {
+	if !b.IsOpen || b.Font == nil {
+		return
+	}
+	vector.DrawFilledRect(screen, 0, 0, float32(b.width), float32(b.height), color.RGBA{0, 0, 0, 200}, false)
+
+	lineHeight := b.Font.Metrics().Height.Round()
+	maxWidth := b.width - backlogMargin*2
+	y := b.height - backlogMargin
+	b.rects = b.rects[:0]
+
+	for i := len(b.Entries) - 1 - b.scroll; i >= 0; i-- {
+		entry := b.Entries[i]
+		lines := wrapLines(b.Font, entry.Text, maxWidth)
+		if entry.Speaker != "" {
+			lines = append([]string{"【" + entry.Speaker + "】"}, lines...)
+		}
+		height := len(lines) * lineHeight
+		top := y - height
+		if top < backlogMargin {
+			break
+		}
+
+		textColor := color.Color(color.White)
+		if i == b.hovered && entry.Voice != "" {
+			textColor = color.RGBA{0, 255, 0, 255}
+		}
+		for j, line := range lines {
+			text.Draw(screen, line, b.Font, backlogMargin, top+(j+1)*lineHeight, textColor)
+		}
+		b.rects = append(b.rects, backlogRect{
+			index: i,
+			rect:  Rect{X: backlogMargin, Y: top, Width: maxWidth, Height: height},
+		})
+		y = top - backlogEntryGap
+	}
+}
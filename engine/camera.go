@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"RenGO/script"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image/color"
+	"math/rand"
+)
+
+// Camera 把合成后的场景画到屏幕上：缩放、平移、震动、色调和闪光。
+// 缩放、镜头位置和色调会一直保持并写入存档，震动和闪光只是一瞬间的效果
+type Camera struct {
+	Zoom  float64 `json:"zoom"`
+	X     float64 `json:"x"` // 画面中心对准的场景坐标
+	Y     float64 `json:"y"`
+	TintR float64 `json:"tint_r"` // 各颜色通道的倍率，1 为原色
+	TintG float64 `json:"tint_g"`
+	TintB float64 `json:"tint_b"`
+
+	shake      float64 // 当前震动幅度，单位为像素
+	flash      float64 // 闪光的不透明度
+	flashColor []float32
+
+	width, height int
+	pixel         *ebiten.Image // 绘制闪光用的白色像素
+}
+
+func NewCamera(width, height int) *Camera {
+	c := &Camera{width: width, height: height}
+	c.Reset()
+	return c
+}
+
+// Reset 恢复默认镜头并停止震动和闪光
+func (c *Camera) Reset() {
+	c.Zoom, c.X, c.Y = 1, float64(c.width)/2, float64(c.height)/2
+	c.TintR, c.TintG, c.TintB = 1, 1, 1
+	c.shake, c.flash = 0, 0
+}
+
+// saved 默认镜头返回 nil，存档中省略
+func (c *Camera) saved() *Camera {
+	if c.Zoom == 1 && c.X == float64(c.width)/2 && c.Y == float64(c.height)/2 &&
+		c.TintR == 1 && c.TintG == 1 && c.TintB == 1 {
+		return nil
+	}
+	return &Camera{Zoom: c.Zoom, X: c.X, Y: c.Y, TintR: c.TintR, TintG: c.TintG, TintB: c.TintB}
+}
+
+func (c *Camera) restore(state *Camera) {
+	c.Reset()
+	if state != nil {
+		c.Zoom, c.X, c.Y = state.Zoom, state.X, state.Y
+		c.TintR, c.TintG, c.TintB = state.TintR, state.TintG, state.TintB
+	}
+}
+
+// Draw 把场景画到屏幕上
+func (c *Camera) Draw(screen, scene *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-c.X, -c.Y)
+	op.GeoM.Scale(c.Zoom, c.Zoom)
+	op.GeoM.Translate(float64(c.width)/2, float64(c.height)/2)
+	if c.shake > 0 {
+		op.GeoM.Translate((rand.Float64()*2-1)*c.shake, (rand.Float64()*2-1)*c.shake)
+	}
+	op.ColorScale.Scale(float32(c.TintR), float32(c.TintG), float32(c.TintB), 1)
+	if c.Zoom != 1 {
+		op.Filter = ebiten.FilterLinear
+	}
+	screen.DrawImage(scene, op)
+
+	if c.flash > 0 {
+		if c.pixel == nil {
+			c.pixel = ebiten.NewImage(1, 1)
+			c.pixel.Fill(color.White)
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(float64(c.width), float64(c.height))
+		a := float32(c.flash)
+		op.ColorScale.Scale(c.flashColor[0]*a, c.flashColor[1]*a, c.flashColor[2]*a, a)
+		screen.DrawImage(c.pixel, op)
+	}
+}
+
+// CameraCommand 执行镜头命令，变化通过补间动画完成
+func (e *Engine) CameraCommand(command string, args []string) error {
+	cmd, err := script.ParseCameraCommand(command, args)
+	if err != nil {
+		return err
+	}
+	c := e.Camera
+	tween := func(value *float64, to float64) *Tween {
+		return newTween(value, to, cmd.Duration, cmd.Ease, cmd.Wait)
+	}
+	switch command {
+	case "shake":
+		// 幅度线性衰减到 0
+		c.shake = cmd.Values[0]
+		e.Tweens.Add(newTween(&c.shake, 0, cmd.Duration, script.Easings["linear"], cmd.Wait))
+	case "flash":
+		c.flash, c.flashColor = 1, cmd.Color
+		e.Tweens.Add(newTween(&c.flash, 0, cmd.Duration, script.Easings["ease-out"], cmd.Wait))
+	case "tint":
+		e.Tweens.Add(
+			tween(&c.TintR, float64(cmd.Color[0])),
+			tween(&c.TintG, float64(cmd.Color[1])),
+			tween(&c.TintB, float64(cmd.Color[2])),
+		)
+	case "zoom":
+		tweens := []*Tween{tween(&c.Zoom, cmd.Values[0])}
+		if len(cmd.Values) == 3 {
+			tweens = append(tweens, tween(&c.X, cmd.Values[1]), tween(&c.Y, cmd.Values[2]))
+		}
+		e.Tweens.Add(tweens...)
+	case "pan":
+		e.Tweens.Add(tween(&c.X, cmd.Values[0]), tween(&c.Y, cmd.Values[1]))
+	}
+	return nil
+}
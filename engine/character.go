@@ -1,115 +1,186 @@
 package engine
 
 import (
-	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"log"
 	"sync"
 )
 
+// CharacterDisplay 一个图层上同时显示的多个立绘，按角色名区分
 type CharacterDisplay struct {
-	characters           map[string]*Character
-	current              *Character
-	positionX, positionY float64
-	mutex                sync.RWMutex
+	characters map[string]*Character
+	order      []string         // 显示顺序，后显示的立绘画在上面
+	res        *ResourceManager // 为 nil 时只记录状态不加载图片，用于无窗口模式
+	mutex      sync.RWMutex
 }
 
+// Character 一个显示中的立绘，X、Y 是立绘底边中点在画面上的位置。
+// 单张图片的立绘记录 Path，组合立绘记录每组选中的部件 Parts
 type Character struct {
 	Name  string
+	Path  string
+	Parts map[string]string
+	Slot  string
+	X, Y  float64
 	Image *ebiten.Image
+	Transform
 }
 
-func NewCharacterDisplay() *CharacterDisplay {
+func NewCharacterDisplay(res *ResourceManager) *CharacterDisplay {
 	return &CharacterDisplay{
 		characters: make(map[string]*Character),
+		res:        res,
 	}
 }
 
-func (cd *CharacterDisplay) LoadCharacter(characterName, imagePath string) error {
+// Show 显示立绘，角色已在图层上时换成新图片并移到新位置
+func (cd *CharacterDisplay) Show(name, path, slot string, x, y float64) error {
 	cd.mutex.Lock()
 	defer cd.mutex.Unlock()
 
-	img, _, err := ebitenutil.NewImageFromFile(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to load character image %s: %v", imagePath, err)
+	var img *ebiten.Image
+	if cd.res != nil {
+		var err error
+		if img, err = cd.res.Image(path); err != nil {
+			return err
+		}
 	}
 
-	cd.characters[characterName] = &Character{
-		Name:  characterName,
-		Image: img,
-	}
+	cd.put(&Character{Name: name, Path: path, Slot: slot, X: x, Y: y, Image: img, Transform: identityTransform()})
 	return nil
 }
 
-func (cd *CharacterDisplay) SetCurrentCharacter(name string) {
+// ShowParts 显示组合立绘，选中的部件按顺序合成为一张图片
+func (cd *CharacterDisplay) ShowParts(def *CharacterDef, parts map[string]string, slot string, x, y float64) error {
 	cd.mutex.Lock()
 	defer cd.mutex.Unlock()
 
-	if char, ok := cd.characters[name]; ok {
-		cd.current = char
+	var img *ebiten.Image
+	if cd.res != nil {
+		var err error
+		if img, err = cd.compose(def.Layers(parts)); err != nil {
+			return err
+		}
+	}
+	cd.put(&Character{Name: def.Name, Parts: parts, Slot: slot, X: x, Y: y, Image: img, Transform: identityTransform()})
+	return nil
+}
+
+// put 登记立绘，替换同名立绘时释放旧的并保留它的偏移、缩放和旋转，调用方持有锁。
+// 不透明度不保留，进行中的淡入淡出会按角色名继续作用在新立绘上
+func (cd *CharacterDisplay) put(char *Character) {
+	if old, ok := cd.characters[char.Name]; ok {
+		alpha := char.Alpha
+		char.Transform = old.Transform
+		char.Alpha = alpha
+		cd.release(old)
+	} else {
+		cd.order = append(cd.order, char.Name)
+	}
+	cd.characters[char.Name] = char
+}
+
+// compose 把部件画到一张与第一个部件同样大小的图片上。部件图片只在合成时引用，
+// 之后留在缓存中，换表情时不必重新解码
+func (cd *CharacterDisplay) compose(layers []Part) (*ebiten.Image, error) {
+	images := make([]*ebiten.Image, 0, len(layers))
+	defer func() {
+		for i := range images {
+			cd.res.ReleaseImage(layers[i].Image)
+		}
+	}()
+	for _, part := range layers {
+		img, err := cd.res.Image(part.Image)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	bounds := images[0].Bounds()
+	out := ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	for i, img := range images {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(layers[i].X, layers[i].Y)
+		out.DrawImage(img, op)
 	}
+	return out, nil
 }
 
-func (cd *CharacterDisplay) SetPosition(x, y float64) {
+// Hide 移除立绘，角色不在图层上时返回 false
+func (cd *CharacterDisplay) Hide(name string) bool {
 	cd.mutex.Lock()
 	defer cd.mutex.Unlock()
 
-	cd.positionX = x
-	cd.positionY = y
+	char, ok := cd.characters[name]
+	if !ok {
+		return false
+	}
+	cd.release(char)
+	delete(cd.characters, name)
+	for i, n := range cd.order {
+		if n == name {
+			cd.order = append(cd.order[:i], cd.order[i+1:]...)
+			break
+		}
+	}
+	return true
 }
 
-func (cd *CharacterDisplay) Update() {
-	// Add any character animation or movement logic here
+// Get 返回显示中的立绘
+func (cd *CharacterDisplay) Get(name string) (*Character, bool) {
+	cd.mutex.RLock()
+	defer cd.mutex.RUnlock()
+
+	char, ok := cd.characters[name]
+	return char, ok
 }
 
-func (cd *CharacterDisplay) Draw(screen *ebiten.Image) {
+// Characters 按显示顺序返回所有立绘
+func (cd *CharacterDisplay) Characters() []*Character {
 	cd.mutex.RLock()
 	defer cd.mutex.RUnlock()
 
-	if cd.current != nil && cd.current.Image != nil {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(cd.positionX, cd.positionY)
-		screen.DrawImage(cd.current.Image, op)
+	chars := make([]*Character, 0, len(cd.order))
+	for _, name := range cd.order {
+		chars = append(chars, cd.characters[name])
 	}
+	return chars
 }
-func (cd *CharacterDisplay) SetCharacter(characterName string) {
-	cd.mutex.Lock()
-	defer cd.mutex.Unlock()
 
-	if char, exists := cd.characters[characterName]; exists {
-		cd.current = char
+func (cd *CharacterDisplay) release(char *Character) {
+	if cd.res == nil {
+		return
+	}
+	if char.Parts != nil {
+		// 合成的图片只属于这个立绘
+		char.Image.Deallocate()
 	} else {
-		log.Printf("Warning: Character %s not found", characterName)
+		cd.res.ReleaseImage(char.Path)
+	}
+}
+
+// Draw 以底边中点为基准绘制立绘
+func (char *Character) Draw(screen *ebiten.Image) {
+	if char.Image == nil {
+		return
 	}
+	w, h := char.Image.Bounds().Dx(), char.Image.Bounds().Dy()
+	op := &ebiten.DrawImageOptions{}
+	char.Transform.apply(op, float64(w)/2, float64(h), char.X, char.Y)
+	screen.DrawImage(char.Image, op)
 }
 
 func (cd *CharacterDisplay) Clear() {
 	cd.mutex.Lock()
 	defer cd.mutex.Unlock()
 
-	cd.current = nil
-}
-func (cd *CharacterDisplay) Clone() *CharacterDisplay {
-	newCD := &CharacterDisplay{
-		characters: make(map[string]*Character),
-		positionX:  cd.positionX,
-		positionY:  cd.positionY,
-	}
-	for k, v := range cd.characters {
-		newCD.characters[k] = v.Clone()
-	}
-	if cd.current != nil {
-		newCD.current = cd.current.Clone()
-	}
-	return newCD
-}
-func (c *Character) Clone() *Character {
-	return &Character{
-		Name:  c.Name,
-		Image: c.Image,
+	for _, char := range cd.characters {
+		cd.release(char)
 	}
+	cd.characters = make(map[string]*Character)
+	cd.order = nil
 }
+
 func (cd *CharacterDisplay) IsReady() bool {
 	// 根据你的需求实现这个方法
 	// 例如，可以检查是否所有效果都已完成
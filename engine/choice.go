@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"RenGO/script"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
@@ -8,21 +9,17 @@ import (
 	"image/color"
 )
 
-type Choice struct {
-	Text   string
-	JumpTo string
-	Rect   Rect // 用于检测鼠标位置
-}
-
 type Rect struct {
 	X, Y, Width, Height int
 }
 
 type ChoiceManager struct {
-	Choices      []Choice
+	Choices      []script.Choice
+	rects        []Rect // 各选项在画面上的位置，用于检测鼠标位置
 	HoveredIndex int
 	Font         font.Face
 	IsActive     bool
+	lastX, lastY int // 上一帧的鼠标位置，鼠标不动时保留键盘选中的选项
 }
 
 func NewChoiceManager(font font.Face) *ChoiceManager {
@@ -33,13 +30,18 @@ func NewChoiceManager(font font.Face) *ChoiceManager {
 	}
 }
 
-func (cm *ChoiceManager) SetChoices(choices []Choice, screenWidth int) {
+func (cm *ChoiceManager) SetChoices(choices []script.Choice, screenWidth int) {
 	cm.Choices = choices
 	cm.IsActive = true
+	cm.HoveredIndex = -1 // 不沿用上一个菜单的选中项
 	cm.updateChoiceRects(screenWidth)
 }
 
 func (cm *ChoiceManager) updateChoiceRects(screenWidth int) {
+	cm.rects = make([]Rect, len(cm.Choices))
+	if cm.Font == nil {
+		return // 无窗口模式下没有字体，不需要排版
+	}
 	y := 100 // 起始 Y 坐标
 	for i := range cm.Choices {
 		bounds := text.BoundString(cm.Font, cm.Choices[i].Text)
@@ -49,7 +51,7 @@ func (cm *ChoiceManager) updateChoiceRects(screenWidth int) {
 		// 计算居中的 X 坐标
 		x := (screenWidth - textWidth) / 2
 
-		cm.Choices[i].Rect = Rect{
+		cm.rects[i] = Rect{
 			X:      x - 10,          // 左边距
 			Y:      y,               // 当前 Y 坐标
 			Width:  textWidth + 20,  // 文本宽度 + 边距
@@ -59,25 +61,41 @@ func (cm *ChoiceManager) updateChoiceRects(screenWidth int) {
 	}
 }
 
-func (cm *ChoiceManager) HandleInput() (selected bool, jumpTo string) {
-	if !cm.IsActive {
+func (cm *ChoiceManager) HandleInput(input *InputMap) (selected bool, jumpTo string) {
+	if !cm.IsActive || len(cm.Choices) == 0 {
 		return false, ""
 	}
 
-	// 处理鼠标输入
+	// 处理鼠标输入，只有鼠标移动或点击时才按光标位置更新选中项
 	x, y := ebiten.CursorPosition()
-	cm.HoveredIndex = -1
-	for i, choice := range cm.Choices {
-		rect := choice.Rect
-		if x >= rect.X && x <= rect.X+rect.Width &&
-			y >= rect.Y && y <= rect.Y+rect.Height {
-			cm.HoveredIndex = i
-			break
+	clicked := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+	if x != cm.lastX || y != cm.lastY || clicked {
+		cm.lastX, cm.lastY = x, y
+		cm.HoveredIndex = -1
+		for i, rect := range cm.rects {
+			if x >= rect.X && x <= rect.X+rect.Width &&
+				y >= rect.Y && y <= rect.Y+rect.Height {
+				cm.HoveredIndex = i
+				break
+			}
+		}
+	}
+
+	// 处理键盘和手柄输入
+	n := len(cm.Choices)
+	switch {
+	case input.JustPressed(ActionUp):
+		if cm.HoveredIndex < 0 {
+			cm.HoveredIndex = n - 1
+		} else {
+			cm.HoveredIndex = (cm.HoveredIndex + n - 1) % n
 		}
+	case input.JustPressed(ActionDown):
+		cm.HoveredIndex = (cm.HoveredIndex + 1) % n
 	}
 
-	// 处理鼠标点击
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && cm.HoveredIndex != -1 {
+	// 处理鼠标点击和确定键
+	if (clicked || input.JustPressed(ActionConfirm)) && cm.HoveredIndex >= 0 && cm.HoveredIndex < n {
 		selected = true
 		jumpTo = cm.Choices[cm.HoveredIndex].JumpTo
 		cm.IsActive = false // 关闭选项显示
@@ -107,8 +125,9 @@ func (cm *ChoiceManager) Draw(screen *ebiten.Image) {
 		}
 
 		// 文本位置
-		textX := choice.Rect.X + 10
-		textY := choice.Rect.Y + (choice.Rect.Height / 2) + 10 // 垂直居中
+		rect := cm.rects[i]
+		textX := rect.X + 10
+		textY := rect.Y + (rect.Height / 2) + 10 // 垂直居中
 
 		// 阴影偏移量
 		shadowOffsetX := 2.0
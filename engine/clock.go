@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"time"
+)
+
+const (
+	maxFrameTime  = 0.1  // 单帧最多推进的秒数，避免拖动窗口等卡顿之后动画一下跳到结束
+	skipTimeScale = 10.0 // 快进时游戏时间的倍率
+)
+
+// Clock 游戏时钟，每帧测量真实经过的时间，乘以游戏速度得到游戏时间。
+// 特效、文字显示、动画和标题界面都按游戏时间推进，与刷新率无关
+type Clock struct {
+	Speed float64 // 游戏速度倍率，1 为正常速度
+	Scale float64 // 临时倍率，快进时加速
+
+	last time.Time
+	real float64
+	now  func() time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock{Speed: 1, Scale: 1, now: time.Now}
+}
+
+// Tick 每次 Update 开始时调用，记录距上一帧的真实时间
+func (c *Clock) Tick() {
+	now := c.now()
+	if c.last.IsZero() {
+		c.real = 0
+	} else {
+		c.real = min(now.Sub(c.last).Seconds(), maxFrameTime)
+	}
+	c.last = now
+}
+
+// RealDelta 本帧经过的真实秒数，不受游戏速度影响，用于音频淡入淡出等
+func (c *Clock) RealDelta() float64 {
+	return c.real
+}
+
+// Delta 本帧经过的游戏时间秒数
+func (c *Clock) Delta() float64 {
+	return c.real * c.Speed * c.Scale
+}
@@ -1,14 +1,19 @@
 package engine
 
 import (
+	"RenGO/config"
+	"RenGO/script"
 	"fmt"
-	"github.com/golang/freetype/truetype"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"golang.org/x/image/font"
 	"log"
-	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 type Layer struct {
@@ -16,94 +21,186 @@ type Layer struct {
 	CharDisplay  *CharacterDisplay
 	Visible      bool
 	ZIndex       int
+	ImagePath    string // 当前显示图片的路径，用于存档
 }
 
 type Engine struct {
 	Layers            []*Layer
 	titleUI           *TitleUI
 	CurrentImageLayer int
-	CurrentCharLayer  int
-	currentChoices    []Choice
+	currentChoices    []script.Choice
 	AffectionSystem   *AffectionSystem
 	ChoiceSystem      *ChoiceManager
-	EffectSystem      *EffectSystem
+	Transitions       *TransitionSystem
 	TextDisplay       *TextDisplay
 	Width, Height     int
+	Config            *config.Game
 	ScriptEngine      *ScriptEngine
+	Saves             *SaveManager
+	Audio             *AudioManager
+	Settings          *Settings
+	Backlog           *Backlog
+	Reads             *ReadTracker
+	Assets            *Assets
+	Resources         *ResourceManager
+	characterDefs     map[string]*CharacterDef
+	Tweens            *TweenSystem
+	scene             *ebiten.Image // 所有图层合成后的画面，不含文本框等界面
+	Camera            *Camera
+	PostEffects       *PostEffects
+	Clock             *Clock
 	mutex             sync.RWMutex
 	FontFace          *font.Face
 	state             string
+	headless          bool    // 无窗口模式，只记录资源路径不解码图片
+	skipMode          bool    // 快进开关，按住 Ctrl 时也会快进
+	autoMode          bool    // 自动播放开关
+	autoWait          float64 // 当前行显示完后已等待的秒数
+	QuickMenu         *QuickMenu
+	Input             *InputMap
+	hideUI            bool // 隐藏文本框、选项和快捷菜单，只显示画面
 }
 
 const (
-	defaultFontSize = 24
-	defaultFontDPI  = 72
+	skipStepsPerFrame = 100
+	defaultSlot       = "center" // 新显示的立绘默认位置
 )
 
-var defaultFont font.Face
-
-func loadDefaultFont() font.Face {
-	if defaultFont != nil {
-		return defaultFont
+// NewEngine 按 game.json 中的配置创建引擎
+func NewEngine(cfg *config.Game) (*Engine, error) {
+	assets, err := OpenAssets(cfg)
+	if err != nil {
+		return nil, err
 	}
-
-	fontBytes, err := os.ReadFile("./resource/font/font.ttf")
+	scripts, err := assets.Sub(cfg.ScriptDir)
 	if err != nil {
-		log.Fatal(fmt.Errorf("failed to load font file: %v", err))
+		return nil, err
 	}
-
-	parsedFont, err := truetype.Parse(fontBytes)
+	e := newEngine(cfg, assets, script.NewRegistryFS(scripts, cfg.ScriptRoot()), false)
+	textFont, err := e.Resources.Font(cfg.Font.Path, cfg.Font.Size, cfg.Font.DPI)
 	if err != nil {
-		log.Fatal(fmt.Errorf("failed to parse font: %v", err))
+		return nil, err
+	}
+	choiceFont := textFont
+	if cfg.ChoiceFont != nil {
+		if choiceFont, err = e.Resources.Font(cfg.ChoiceFont.Path, cfg.ChoiceFont.Size, cfg.ChoiceFont.DPI); err != nil {
+			return nil, err
+		}
 	}
 
-	defaultFont = truetype.NewFace(parsedFont, &truetype.Options{
-		Size:    defaultFontSize,
-		DPI:     defaultFontDPI,
-		Hinting: font.HintingFull,
+	if path, err := userConfigPath(settingsFileName); err != nil {
+		log.Printf("Using default settings: %v", err)
+	} else {
+		settings, err := LoadSettings(path)
+		if err != nil {
+			log.Printf("Using default settings: %v", err)
+		}
+		e.Settings = settings
+		e.ApplySettings()
+		if err := e.Input.Apply(settings.KeyBindings, settings.GamepadBindings); err != nil {
+			log.Printf("Ignoring %v", err)
+		}
+	}
+	if path, err := userConfigPath(readTrackerFileName); err != nil {
+		log.Printf("Read lines will not be remembered: %v", err)
+	} else {
+		reads, err := LoadReadTracker(path)
+		if err != nil {
+			log.Printf("Failed to load read lines: %v", err)
+		}
+		e.Reads = reads
+	}
+	e.TextDisplay.SetFont(textFont)
+	e.ChoiceSystem.Font = choiceFont
+	e.Backlog.Font = textFont
+	e.QuickMenu.SetFont(textFont)
+
+	e.titleUI = NewTitleUI(e, func() {
+		e.state = "game"
+		err := e.ScriptEngine.LoadScript(cfg.EntryScript)
+		if err != nil {
+			log.Fatal(err)
+		}
+		e.ScriptEngine.ClearHistory()
+		e.ScriptEngine.ExecuteStep()
+	}, func() {
+		slot, ok := e.Saves.Latest()
+		if !ok {
+			log.Println("No save data found")
+			return
+		}
+		if err := e.LoadGame(slot); err != nil {
+			log.Printf("Failed to load game: %v", err)
+		}
 	})
+	return e, nil
+}
 
-	return defaultFont
+// NewHeadlessEngine 创建不打开窗口、不加载字体和图片的引擎，用于测试和命令行工具
+func NewHeadlessEngine(width, height, layerCount int, registry *script.Registry) *Engine {
+	cfg := config.Default()
+	cfg.Width, cfg.Height, cfg.Layers = width, height, layerCount
+	e := newEngine(cfg, NewDirAssets(cfg.ResourceRoot), registry, true)
+	e.state = "game"
+	return e
 }
 
-func NewEngine(width, height, layerCount int) *Engine {
-	loadDefaultFont()
+func newEngine(cfg *config.Game, assets *Assets, registry *script.Registry, headless bool) *Engine {
 	e := &Engine{
-		Layers:            make([]*Layer, layerCount),
+		Layers:            make([]*Layer, cfg.Layers),
 		CurrentImageLayer: -1,
-		CurrentCharLayer:  -1,
-		TextDisplay:       NewTextDisplay(200, float64(height-100)),
-		ChoiceSystem:      NewChoiceManager(defaultFont),
+		ChoiceSystem:      NewChoiceManager(nil),
 		AffectionSystem:   NewAffectionSystem(),
-		Width:             width,
-		Height:            height,
+		TextDisplay:       NewTextDisplay(cfg.TextBox.X, cfg.TextBox.Y),
+		Saves:             NewSaveManager(cfg.SaveDir),
+		Settings:          DefaultSettings(),
+		Reads:             NewReadTracker(),
+		Input:             DefaultInputMap(),
+		Config:            cfg,
+		Assets:            assets,
+		characterDefs:     make(map[string]*CharacterDef),
+		Width:             cfg.Width,
+		Height:            cfg.Height,
 		state:             "title",
+		headless:          headless,
 	}
+	e.Resources = NewResourceManager(assets)
 
+	// 无窗口模式下立绘只记录状态，不加载图片
+	charRes := e.Resources
+	if headless {
+		charRes = nil
+	}
 	for i := range e.Layers {
 		e.Layers[i] = &Layer{
-			ImageDisplay: NewImageDisplay(),
-			CharDisplay:  NewCharacterDisplay(),
+			ImageDisplay: NewImageDisplay(e.Resources),
+			CharDisplay:  NewCharacterDisplay(charRes),
 			Visible:      true,
 			ZIndex:       i,
 		}
 	}
-	e.ScriptEngine = NewScriptEngine(e)
-	e.EffectSystem = NewEffectSystem(e)
-	e.TextDisplay.SetFont(defaultFont)
-
-	e.titleUI = NewTitleUI(e, func() {
-		e.state = "game"
-		err := e.ScriptEngine.LoadScript("./resource/script/first.rgo")
-		if err != nil {
-			log.Fatal(err)
-		}
-		e.ScriptEngine.ExecuteStep()
-	})
+	if headless {
+		e.Audio = NewAudioManager(nullAudioOutput{})
+	} else {
+		e.Audio = NewAudioManager(newEbitenAudioOutput(e.Resources))
+	}
+	e.TextDisplay.MaxWidth = cfg.TextBox.MaxWidth
+	e.TextDisplay.CharDelay = 1 / cfg.TextBox.TextSpeed
+	if c, err := config.ParseColor(cfg.TextBox.Color); err == nil {
+		e.TextDisplay.Color = c
+	}
+	e.Backlog = NewBacklog(e, e.Width, e.Height)
+	e.QuickMenu = NewQuickMenu(e)
+	e.ScriptEngine = NewScriptEngine(e, registry)
+	e.Transitions = NewTransitionSystem(e.Resources, e.Width, e.Height, headless)
+	e.Tweens = NewTweenSystem(headless)
+	e.Clock = NewClock()
+	e.Camera = NewCamera(e.Width, e.Height)
+	e.PostEffects = NewPostEffects(NewShaderRegistry(assets, cfg.ShaderDir, !headless), e.Width, e.Height)
 	return e
 }
 
-func (e *Engine) ShowChoices(choices []Choice) {
+func (e *Engine) ShowChoices(choices []script.Choice) {
 	e.currentChoices = choices
 	// 在游戏界面上显示选项
 	for i, choice := range choices {
@@ -128,43 +225,149 @@ func (e *Engine) SetLayerImage(layerIndex int, imageName, imagePath string) erro
 		// 清除当前活动的图片图层
 		if e.CurrentImageLayer >= 0 && e.CurrentImageLayer < len(e.Layers) {
 			e.Layers[e.CurrentImageLayer].ImageDisplay.Clear()
+			e.Layers[e.CurrentImageLayer].ImagePath = ""
 		}
 
-		layer := e.Layers[layerIndex]
-		if err := layer.ImageDisplay.LoadImage(imageName, imagePath); err != nil {
+		if err := e.showLayerImage(e.Layers[layerIndex], imageName, imagePath); err != nil {
 			return err
 		}
-		layer.ImageDisplay.SetImage(imageName)
-		layer.Visible = true
 		e.CurrentImageLayer = layerIndex
 		return nil
 	}
 	return fmt.Errorf("layer index out of range")
 }
 
-func (e *Engine) SetLayerCharacter(layerIndex int, characterName, characterPath string) error {
-	if layerIndex >= 0 && layerIndex < len(e.Layers) {
-		// 清除当前活动的立绘图层
-		if e.CurrentCharLayer >= 0 && e.CurrentCharLayer < len(e.Layers) {
-			e.Layers[e.CurrentCharLayer].CharDisplay.Clear()
+// ShowCharacter 在图层上显示单张图片的角色立绘，slot 为空时沿用角色当前的位置
+func (e *Engine) ShowCharacter(layerIndex int, name, path, slot string) error {
+	return e.placeCharacter(layerIndex, name, slot, func(cd *CharacterDisplay, slot string, x, y float64) error {
+		return cd.Show(name, path, slot, x, y)
+	})
+}
+
+// legacyCharacter 兼容旧版的 @chara 图层 位置 图片：第二个参数是配置中的位置名时
+// 把它当作位置，角色名取图片的文件名；否则它就是角色名，位置沿用默认
+func (e *Engine) legacyCharacter(name, imagePath string) (string, string) {
+	if _, ok := e.Config.Slots[name]; !ok {
+		return name, ""
+	}
+	base := path.Base(filepath.ToSlash(imagePath))
+	return strings.TrimSuffix(base, path.Ext(base)), name
+}
+
+// ShowCharacterParts 显示组合立绘，只替换 parts 中部件所在的组，其余部件保持不变。
+// layerIndex 为 -1 时沿用角色当前的图层，新角色放在配置的立绘图层
+func (e *Engine) ShowCharacterParts(layerIndex int, name, slot string, parts []string) error {
+	def, err := e.characterDef(name)
+	if err != nil {
+		return err
+	}
+	var current map[string]string
+	if layer, char := e.findCharacter(name); char != nil {
+		current = char.Parts
+		if layerIndex < 0 {
+			layerIndex = e.layerIndex(layer)
 		}
+	}
+	if layerIndex < 0 {
+		layerIndex = e.Config.CharacterLayer
+	}
+	selected, err := def.Select(current, parts)
+	if err != nil {
+		return err
+	}
+	return e.placeCharacter(layerIndex, name, slot, func(cd *CharacterDisplay, slot string, x, y float64) error {
+		return cd.ShowParts(def, selected, slot, x, y)
+	})
+}
 
-		layer := e.Layers[layerIndex]
-		if err := layer.CharDisplay.LoadCharacter(characterName, characterPath); err != nil {
+// placeCharacter 计算立绘位置后交给 show 显示，新角色默认居中。
+// 同一角色只会出现一次，已在其他图层上时先从那里移除
+func (e *Engine) placeCharacter(layerIndex int, name, slot string, show func(cd *CharacterDisplay, slot string, x, y float64) error) error {
+	if layerIndex < 0 || layerIndex >= len(e.Layers) {
+		return fmt.Errorf("layer index out of range")
+	}
+	if from, char := e.findCharacter(name); char != nil {
+		if slot == "" {
+			slot = char.Slot
+		}
+		if from != e.Layers[layerIndex] {
+			from.CharDisplay.Hide(name)
+		}
+	}
+	if slot == "" {
+		slot = defaultSlot
+	}
+	x, y, err := e.Config.SlotPosition(slot)
+	if err != nil {
+		return err
+	}
+	layer := e.Layers[layerIndex]
+	if err := show(layer.CharDisplay, slot, x, y); err != nil {
+		return err
+	}
+	layer.Visible = true
+	return nil
+}
+
+// HideCharacter 移除角色立绘
+func (e *Engine) HideCharacter(name string) error {
+	layer, _ := e.findCharacter(name)
+	if layer == nil {
+		return fmt.Errorf("character %s is not on screen", name)
+	}
+	layer.CharDisplay.Hide(name)
+	return nil
+}
+
+func (e *Engine) layerIndex(layer *Layer) int {
+	for i, l := range e.Layers {
+		if l == layer {
+			return i
+		}
+	}
+	return -1
+}
+
+// findCharacter 查找角色所在的图层
+func (e *Engine) findCharacter(name string) (*Layer, *Character) {
+	for _, layer := range e.Layers {
+		if char, ok := layer.CharDisplay.Get(name); ok {
+			return layer, char
+		}
+	}
+	return nil, nil
+}
+
+// showLayerImage 在图层上显示图片，无窗口模式下只记录路径
+func (e *Engine) showLayerImage(layer *Layer, imageName, imagePath string) error {
+	if !e.headless {
+		if err := layer.ImageDisplay.LoadImage(imageName, imagePath); err != nil {
 			return err
 		}
-		layer.CharDisplay.SetCharacter(characterName)
-		layer.Visible = true
-		e.CurrentCharLayer = layerIndex
-		return nil
+		layer.ImageDisplay.SetImage(imageName)
 	}
-	return fmt.Errorf("layer index out of range")
+	layer.ImagePath = imagePath
+	layer.Visible = true
+	return nil
 }
+
 func (e *Engine) ClearLayer(layerIndex int) error {
 	if layerIndex >= 0 && layerIndex < len(e.Layers) {
 		layer := e.Layers[layerIndex]
 		layer.ImageDisplay.Clear()
 		layer.CharDisplay.Clear()
+		layer.ImagePath = ""
+		return nil
+	}
+	return fmt.Errorf("layer index out of range")
+}
+
+// ClearLayerImage 只移除图层图片，图层上的立绘保留
+func (e *Engine) ClearLayerImage(layerIndex int) error {
+	if layerIndex >= 0 && layerIndex < len(e.Layers) {
+		layer := e.Layers[layerIndex]
+		layer.ImageDisplay.Clear()
+		layer.ImagePath = ""
 		return nil
 	}
 	return fmt.Errorf("layer index out of range")
@@ -175,51 +378,136 @@ var isMouseButtonPressed bool // 用于记录鼠标按钮状态
 func (e *Engine) Update() error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
+	e.Input.Update()
+	e.Clock.Tick()
+	e.Clock.Scale = 1 // 快进时由 skip 设置
 	if e.state == "title" {
+		if err := e.Settings.Update(e.Clock.RealDelta()); err != nil {
+			log.Printf("Failed to save settings: %v", err)
+		}
 		return e.titleUI.Update()
 	}
+	// 离开标题菜单时写入还没保存的设置
+	if err := e.Settings.Flush(); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+	}
+	// 快速存档/读档
+	if e.Input.JustPressed(ActionQuickSave) {
+		if err := e.SaveGame(quickSaveSlot); err != nil {
+			log.Printf("Quick save failed: %v", err)
+		}
+	}
+	if e.Input.JustPressed(ActionQuickLoad) {
+		if err := e.LoadGame(quickSaveSlot); err != nil {
+			log.Printf("Quick load failed: %v", err)
+		}
+	}
+
+	// 历史界面打开时暂停游戏，输入全部交给历史界面
+	if e.Backlog.IsOpen {
+		e.Backlog.Update()
+		e.Audio.Update(e.Clock.RealDelta())
+		return nil
+	}
+	if _, wheel := ebiten.Wheel(); wheel > 0 || e.Input.JustPressed(ActionBacklog) {
+		e.Backlog.Open()
+		return nil
+	}
+	// 回退到上一行
+	if e.Input.JustPressed(ActionRollback) {
+		e.ScriptEngine.Rollback()
+		return nil
+	}
+
+	// 隐藏文本框时任意推进操作都只恢复显示
+	if e.hideUI {
+		if e.Input.JustPressed(ActionHide) || e.Input.JustPressed(ActionAdvance) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			e.hideUI = false
+			isMouseButtonPressed = true
+		}
+		return nil
+	}
+	if e.Input.JustPressed(ActionHide) {
+		e.hideUI = true
+		return nil
+	}
+
+	// 快捷菜单获得键盘焦点时，方向键和确定键交给快捷菜单
+	if e.Input.JustPressed(ActionMenu) {
+		e.QuickMenu.ToggleFocus()
+		return nil
+	}
+	if e.QuickMenu.Focused() {
+		e.QuickMenu.HandleKeys(e.Input)
+		return nil
+	}
+
+	// 快进：切换或按住时临时快进，快进中游戏时间加速
+	if e.Input.JustPressed(ActionSkip) {
+		e.skipMode = !e.skipMode
+	}
+	if e.skipMode || e.Input.Pressed(ActionSkipHold) {
+		e.skip()
+	}
+
+	// 自动播放：按键或快捷菜单切换
+	if e.Input.JustPressed(ActionAuto) {
+		e.SetAutoMode(!e.autoMode)
+	}
+	if e.QuickMenu.HandleInput() {
+		isMouseButtonPressed = true // 点击已被快捷菜单使用，不再推进文本
+	}
+	if e.autoMode {
+		e.auto(e.Clock.Delta())
+	}
+
 	// 更新文字显示进度
-	e.TextDisplay.Update()
+	e.TextDisplay.Update(e.Clock.Delta())
 
-	// 检测鼠标左键点击
+	// 检测鼠标左键点击或推进键
+	advance := e.Input.JustPressed(ActionAdvance)
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
 		if !isMouseButtonPressed { // 只在按下时触发一次
 			isMouseButtonPressed = true
-
-			// 如果当前正在等待输入（显示文字）
-			if e.ScriptEngine.waitingForInput {
-				if !e.TextDisplay.IsReady {
-					// 如果文字没有完全显示，则立即显示完整文字
-					e.TextDisplay.CompleteText()
-				} else {
-					// 如果文字已经完全显示，则继续执行下一步
-					e.ScriptEngine.waitingForInput = false
-				}
-			}
-
-			// 如果当前正在等待选择（显示选项）
-			if e.ScriptEngine.waitingForChoice {
-				// 可以在这里添加处理选择的逻辑
-			}
+			advance = true
 		}
 	} else {
 		isMouseButtonPressed = false // 重置状态
 	}
 
+	// 脚本在等待动画或转场时，点击直接完成
+	if advance && (e.Tweens.Blocking() || e.Transitions.Blocking()) {
+		e.Tweens.Finish()
+		e.Transitions.Finish()
+		advance = false
+	}
+
+	// 如果当前正在等待输入（显示文字）
+	if advance && e.ScriptEngine.waitingForInput {
+		if !e.TextDisplay.IsReady {
+			// 如果文字没有完全显示，则立即显示完整文字
+			e.TextDisplay.CompleteText()
+		} else {
+			// 如果文字已经完全显示，则继续执行下一步
+			e.ScriptEngine.waitingForInput = false
+		}
+	}
+
 	// 执行脚本步骤
 	if e.ScriptEngine.waitingForChoice {
-		selected, jumpTo := e.ChoiceSystem.HandleInput()
+		selected, jumpTo := e.ChoiceSystem.HandleInput(e.Input)
 		if selected {
-			e.ScriptEngine.jumpToLabel(jumpTo)
-			e.ScriptEngine.waitingForChoice = false
-			e.ChoiceSystem.IsActive = false
+			e.ScriptEngine.selectChoice(jumpTo)
 		}
 	} else if !e.ScriptEngine.waitingForInput {
 		e.ScriptEngine.ExecuteStep()
 	}
 
-	// 更新特效系统
-	e.EffectSystem.Update()
+	// 更新转场和动画
+	e.Transitions.Update(e.Clock.Delta())
+	e.PostEffects.Update(e.Clock.Delta())
+	e.Tweens.Update(e.Clock.Delta())
+	e.Audio.Update(e.Clock.RealDelta())
 
 	// 更新选择系统
 	if e.ScriptEngine.waitingForChoice {
@@ -229,6 +517,56 @@ func (e *Engine) Update() error {
 	return nil
 }
 
+// SetAutoMode 开关自动播放
+func (e *Engine) SetAutoMode(on bool) {
+	e.autoMode = on
+	e.autoWait = 0
+}
+
+// autoDelay 当前行显示完后自动前进前的等待时间，随文字长度增加
+func (e *Engine) autoDelay() float64 {
+	chars := utf8.RuneCountInString(e.ScriptEngine.currentText)
+	return e.Settings.AutoBaseDelay + e.Settings.AutoCharDelay*float64(chars)
+}
+
+// auto 文字显示完、语音播放完并等待 autoDelay 后自动前进
+func (e *Engine) auto(dt float64) {
+	se := e.ScriptEngine
+	if !se.waitingForInput || !e.TextDisplay.IsReady || e.Audio.VoicePlaying() {
+		e.autoWait = 0
+		return
+	}
+	e.autoWait += dt
+	if e.autoWait >= e.autoDelay() {
+		e.autoWait = 0
+		se.waitingForInput = false
+	}
+}
+
+// skip 立即显示完当前文本并继续，遇到选择支或未读文本时停止快进
+func (e *Engine) skip() {
+	se := e.ScriptEngine
+	if se.waitingForChoice {
+		e.skipMode = false
+		return
+	}
+	if se.waitingForInput && e.Settings.SkipReadOnly && !se.lineRead {
+		e.skipMode = false
+		return
+	}
+	e.Clock.Scale = skipTimeScale
+	if se.waitingForInput {
+		e.TextDisplay.CompleteText()
+		se.waitingForInput = false
+	}
+	// 一帧内连续执行命令直到下一行文本，避免命令多的段落快进变慢
+	for i := 0; i < skipStepsPerFrame && !se.waitingForInput && !se.waitingForChoice; i++ {
+		if !se.ExecuteStep() {
+			break
+		}
+	}
+}
+
 func (e *Engine) Draw(screen *ebiten.Image) {
 	e.mutex.RLock()
 	defer e.mutex.RUnlock()
@@ -240,27 +578,37 @@ func (e *Engine) Draw(screen *ebiten.Image) {
 		return e.Layers[i].ZIndex < e.Layers[j].ZIndex
 	})
 
-	for _, layer := range e.Layers {
-		if layer.Visible {
-			layer.ImageDisplay.Draw(screen)
-			layer.CharDisplay.Draw(screen)
-		}
+	// 图层先画到离屏的场景图上，整个画面的转场在这里合成，再经过镜头画到屏幕上
+	if e.scene == nil {
+		e.scene = ebiten.NewImage(e.Width, e.Height)
+	} else {
+		e.scene.Clear()
 	}
-
-	e.TextDisplay.Draw(screen)
-	e.ChoiceSystem.Draw(screen)
-
-	e.EffectSystem.Draw(screen)
-
+	e.Transitions.beginFrame()
+	e.Transitions.Draw(e.scene, script.ScreenTarget, e.drawScene)
+	e.Camera.Draw(screen, e.scene)
+
+	if !e.hideUI {
+		e.TextDisplay.Draw(screen)
+		e.ChoiceSystem.Draw(screen)
+		e.QuickMenu.Draw(screen)
+	}
+	e.Backlog.Draw(screen)
 }
 
 func (e *Engine) Run() error {
 	ebiten.SetWindowSize(e.Width, e.Height)
-	ebiten.SetWindowTitle("Visual Novel Engine")
+	ebiten.SetWindowTitle(e.Config.Title)
 	if err := ebiten.RunGame(e); err != nil {
 		log.Fatal(err)
 		return err
 	}
+	if err := e.Reads.Save(); err != nil {
+		log.Printf("Failed to save read lines: %v", err)
+	}
+	if err := e.Settings.Flush(); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+	}
 	return nil
 }
 
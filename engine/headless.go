@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"RenGO/script"
+	"fmt"
+)
+
+const (
+	headlessWidth      = 1280
+	headlessHeight     = 720
+	headlessLayerCount = 5
+	defaultMaxSteps    = 100000
+)
+
+// HeadlessRunner 在没有窗口的情况下驱动 ScriptEngine，用于在测试中遍历剧情分支。
+// 它代替鼠标输入：Advance 相当于点击继续，Choose 相当于点选选项
+type HeadlessRunner struct {
+	Engine   *Engine
+	MaxSteps int // 单次推进最多执行的节点数，防止脚本死循环
+
+	lines   []string
+	visited map[string]bool
+}
+
+// NewHeadlessRunner 从脚本目录加载入口模块，并推进到第一行文本或第一个选择支
+func NewHeadlessRunner(scriptRoot, entry string) (*HeadlessRunner, error) {
+	e := NewHeadlessEngine(headlessWidth, headlessHeight, headlessLayerCount, script.NewRegistry(scriptRoot))
+	r := &HeadlessRunner{
+		Engine:   e,
+		MaxSteps: defaultMaxSteps,
+		visited:  make(map[string]bool),
+	}
+	e.ScriptEngine.labelHook = func(module, label string) {
+		r.visited[module+":"+label] = true
+		r.visited[label] = true
+	}
+
+	if err := e.ScriptEngine.LoadScript(entry); err != nil {
+		return nil, err
+	}
+	if err := r.run(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// run 连续执行节点，直到显示文本、出现选择支或脚本结束
+func (r *HeadlessRunner) run() error {
+	se := r.Engine.ScriptEngine
+	for steps := 0; ; steps++ {
+		if se.waitingForInput || se.waitingForChoice {
+			return nil
+		}
+		if steps >= r.MaxSteps {
+			return fmt.Errorf("script did not stop within %d steps (at %s)", r.MaxSteps, r.Position())
+		}
+		if !se.ExecuteStep() {
+			return nil
+		}
+		if se.waitingForInput {
+			r.Engine.TextDisplay.CompleteText()
+			r.lines = append(r.lines, se.currentText)
+		}
+	}
+}
+
+// Advance 结束当前文本行并继续执行
+func (r *HeadlessRunner) Advance() error {
+	se := r.Engine.ScriptEngine
+	if se.waitingForChoice {
+		return fmt.Errorf("waiting for a choice at %s", r.Position())
+	}
+	se.waitingForInput = false
+	return r.run()
+}
+
+// Choose 选择第 n 个选项（从 0 开始）并继续执行
+func (r *HeadlessRunner) Choose(n int) error {
+	se := r.Engine.ScriptEngine
+	if !se.waitingForChoice {
+		return fmt.Errorf("no choice is shown at %s", r.Position())
+	}
+	if n < 0 || n >= len(se.choicesToShow) {
+		return fmt.Errorf("choice %d out of range (%d options) at %s", n, len(se.choicesToShow), r.Position())
+	}
+	se.selectChoice(se.choicesToShow[n].JumpTo)
+	return r.run()
+}
+
+// Rollback 回到上一行文本或上一个选择支
+func (r *HeadlessRunner) Rollback() error {
+	if !r.Engine.ScriptEngine.Rollback() {
+		return fmt.Errorf("nothing to roll back at %s", r.Position())
+	}
+	return nil
+}
+
+// Play 一路推进文本，遇到选择支时依次使用 choices 中的下标；
+// 脚本结束或 choices 用完后遇到新的选择支时停止
+func (r *HeadlessRunner) Play(choices []int) error {
+	for !r.Finished() {
+		if r.WaitingForChoice() {
+			if len(choices) == 0 {
+				return nil
+			}
+			if err := r.Choose(choices[0]); err != nil {
+				return err
+			}
+			choices = choices[1:]
+			continue
+		}
+		if err := r.Advance(); err != nil {
+			return err
+		}
+	}
+	if len(choices) > 0 {
+		return fmt.Errorf("script ended with %d unused choice(s)", len(choices))
+	}
+	return nil
+}
+
+// Finished 脚本已执行完毕，没有待显示的文本或选项
+func (r *HeadlessRunner) Finished() bool {
+	se := r.Engine.ScriptEngine
+	if se.waitingForInput || se.waitingForChoice || se.pendingJump != "" {
+		return false
+	}
+	return se.script == nil || se.currentLine >= len(se.script.Nodes)
+}
+
+func (r *HeadlessRunner) WaitingForChoice() bool {
+	return r.Engine.ScriptEngine.waitingForChoice
+}
+
+// Text 返回当前显示的文本行
+func (r *HeadlessRunner) Text() string {
+	return r.Engine.ScriptEngine.currentText
+}
+
+// Lines 返回到目前为止显示过的所有文本行
+func (r *HeadlessRunner) Lines() []string {
+	return append([]string(nil), r.lines...)
+}
+
+// Choices 返回当前显示的选项文本
+func (r *HeadlessRunner) Choices() []string {
+	se := r.Engine.ScriptEngine
+	if !se.waitingForChoice {
+		return nil
+	}
+	texts := make([]string, len(se.choicesToShow))
+	for i, c := range se.choicesToShow {
+		texts[i] = c.Text
+	}
+	return texts
+}
+
+// Layer 返回图层当前的内容
+func (r *HeadlessRunner) Layer(i int) LayerState {
+	return captureLayer(r.Engine.Layers[i])
+}
+
+func (r *HeadlessRunner) Variable(name string) (script.Value, bool) {
+	return r.Engine.ScriptEngine.Variable(name)
+}
+
+func (r *HeadlessRunner) Affection(character string) int {
+	return r.Engine.ScriptEngine.Affection(character)
+}
+
+// Visited 判断是否经过某个标签，label 可以写成 "label" 或 "module.rgo:label"
+func (r *HeadlessRunner) Visited(label string) bool {
+	return r.visited[label]
+}
+
+// Position 返回下一个要执行的节点在源文件中的位置
+func (r *HeadlessRunner) Position() script.Pos {
+	se := r.Engine.ScriptEngine
+	if se.script == nil {
+		return script.Pos{}
+	}
+	if se.currentLine < len(se.script.Nodes) {
+		return se.script.Nodes[se.currentLine].Pos
+	}
+	if n := len(se.script.Nodes); n > 0 {
+		return se.script.Nodes[n-1].Pos
+	}
+	return script.Pos{File: se.scriptFile}
+}
+
+// WalkRoutes 通过从头重放选择序列遍历所有分支，每条走到脚本结尾的路线调用一次 visit。
+// maxChoices 限制单条路线的选择次数，超出的路线会被跳过，防止循环剧情无限展开
+func WalkRoutes(newRunner func() (*HeadlessRunner, error), maxChoices int, visit func(route []int, r *HeadlessRunner) error) error {
+	pending := [][]int{{}}
+	for len(pending) > 0 {
+		route := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		r, err := newRunner()
+		if err != nil {
+			return err
+		}
+		if err := r.Play(route); err != nil {
+			return fmt.Errorf("route %v: %v", route, err)
+		}
+		if r.Finished() {
+			if err := visit(route, r); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(route) >= maxChoices {
+			continue
+		}
+		for i := len(r.Choices()) - 1; i >= 0; i-- {
+			next := append(append([]int(nil), route...), i)
+			pending = append(pending, next)
+		}
+	}
+	return nil
+}
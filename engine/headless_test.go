@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"RenGO/script"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const routesDir = "testdata/routes"
+
+func newRoutesRunner() (*HeadlessRunner, error) {
+	return NewHeadlessRunner(routesDir, "main.rgo")
+}
+
+func TestHeadlessPlay(t *testing.T) {
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Text(); got != "Good morning." {
+		t.Errorf("first line %q, want %q", got, "Good morning.")
+	}
+	if err := r.Play(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Choices(), []string{"Say hello", "Stay silent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("choices %q, want %q", got, want)
+	}
+	if got := r.Layer(0).ImagePath; got != "images/bg/room.png" {
+		t.Errorf("layer 0 shows %q", got)
+	}
+	if chars := r.Layer(1).Characters; len(chars) != 1 || chars[0].Name != "alice" || chars[0].Slot != "center" {
+		t.Errorf("layer 1 characters %+v", chars)
+	}
+
+	if err := r.Play([]int{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Finished() {
+		t.Fatalf("not finished at %s", r.Position())
+	}
+	wantLines := []string{"Good morning.", "Hello!", "On the way, Alice hums a song.", "Good ending."}
+	if got := r.Lines(); !reflect.DeepEqual(got, wantLines) {
+		t.Errorf("lines %q, want %q", got, wantLines)
+	}
+	wantVars := map[string]script.Value{
+		"met":    script.BoolVal(true),
+		"route":  script.StringVal("library"),
+		"asides": script.IntVal(1),
+		"ending": script.StringVal("good"),
+	}
+	for name, want := range wantVars {
+		if got, ok := r.Variable(name); !ok || got != want {
+			t.Errorf("variable %s = %v (set %v), want %v", name, got, ok, want)
+		}
+	}
+	if got := r.Affection("alice"); got != 15 {
+		t.Errorf("affection alice = %d, want 15", got)
+	}
+	for _, label := range []string{"hello", "side.rgo:aside", "library", "endings.rgo:good"} {
+		if !r.Visited(label) {
+			t.Errorf("label %s not visited", label)
+		}
+	}
+	for _, label := range []string{"silent", "walk", "endings.rgo:normal"} {
+		if r.Visited(label) {
+			t.Errorf("label %s visited", label)
+		}
+	}
+}
+
+func TestHeadlessChooseErrors(t *testing.T) {
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Choose(0); err == nil {
+		t.Error("Choose succeeded while a text line is shown")
+	}
+	if err := r.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Advance(); err == nil {
+		t.Error("Advance succeeded while a choice is shown")
+	}
+	if err := r.Choose(2); err == nil {
+		t.Error("Choose accepted an out-of-range option")
+	}
+	if err := r.Play([]int{1, 0, 0}); err == nil {
+		t.Error("Play accepted more choices than the script has")
+	}
+}
+
+func TestWalkRoutes(t *testing.T) {
+	endings := make(map[string]string)
+	err := WalkRoutes(newRoutesRunner, 10, func(route []int, r *HeadlessRunner) error {
+		ending, _ := r.Variable("ending")
+		endings[fmt.Sprint(route)] = ending.Str
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"[0 0]": "normal",
+		"[0 1]": "good",
+		"[1 0]": "bad",
+		"[1 1]": "bad",
+	}
+	if !reflect.DeepEqual(endings, want) {
+		t.Errorf("endings %v, want %v", endings, want)
+	}
+
+	// 选择次数上限以内走不完的路线被跳过
+	count := 0
+	if err := WalkRoutes(newRoutesRunner, 1, func([]int, *HeadlessRunner) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d routes finished within one choice", count)
+	}
+}
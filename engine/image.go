@@ -1,19 +1,20 @@
 package engine
 
 import (
-	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"log"
 	"sync"
 )
 
 type ImageDisplay struct {
 	images  map[string]*ebiten.Image
+	paths   map[string]string // 图片名对应的路径，释放引用时使用
+	res     *ResourceManager
 	current *ebiten.Image
 	mask    *ebiten.Image
 	effects map[string]*ImageEffect
 	mutex   sync.RWMutex
+	Transform
 }
 
 type ImageEffect struct {
@@ -22,10 +23,13 @@ type ImageEffect struct {
 	Type     string
 }
 
-func NewImageDisplay() *ImageDisplay {
+func NewImageDisplay(res *ResourceManager) *ImageDisplay {
 	return &ImageDisplay{
-		images:  make(map[string]*ebiten.Image),
-		effects: make(map[string]*ImageEffect),
+		images:    make(map[string]*ebiten.Image),
+		paths:     make(map[string]string),
+		res:       res,
+		Transform: identityTransform(),
+		effects:   make(map[string]*ImageEffect),
 	}
 }
 
@@ -33,12 +37,17 @@ func (id *ImageDisplay) LoadImage(imageName, imagePath string) error {
 	id.mutex.Lock()
 	defer id.mutex.Unlock()
 
-	img, _, err := ebitenutil.NewImageFromFile(imagePath)
+	img, err := id.res.Image(imagePath)
 	if err != nil {
-		return fmt.Errorf("failed to load image %s: %v", imagePath, err)
+		return err
 	}
 
+	// 同名图片被替换时释放旧图片的引用
+	if old, ok := id.paths[imageName]; ok {
+		id.res.ReleaseImage(old)
+	}
 	id.images[imageName] = img
+	id.paths[imageName] = imagePath
 	return nil
 }
 
@@ -76,7 +85,10 @@ func (id *ImageDisplay) Draw(screen *ebiten.Image) {
 	defer id.mutex.RUnlock()
 
 	if id.current != nil {
+		// 以图片中心缩放和旋转
+		w, h := float64(id.current.Bounds().Dx()), float64(id.current.Bounds().Dy())
 		op := &ebiten.DrawImageOptions{}
+		id.Transform.apply(op, w/2, h/2, w/2, h/2)
 		screen.DrawImage(id.current, op)
 	}
 
@@ -91,9 +103,15 @@ func (id *ImageDisplay) Clear() {
 	defer id.mutex.Unlock()
 
 	id.current = nil
+	id.Transform = identityTransform()
 	for k := range id.effects {
 		delete(id.effects, k)
 	}
+	for name, path := range id.paths {
+		id.res.ReleaseImage(path)
+		delete(id.paths, name)
+		delete(id.images, name)
+	}
 }
 
 func (id *ImageDisplay) IsReady() bool {
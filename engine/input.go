@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"sort"
+	"strings"
+)
+
+// Action 与具体按键无关的输入动作
+type Action string
+
+const (
+	ActionAdvance   Action = "advance"    // 推进文本
+	ActionSkip      Action = "skip"       // 切换快进
+	ActionSkipHold  Action = "skip_hold"  // 按住时快进
+	ActionAuto      Action = "auto"       // 切换自动播放
+	ActionBacklog   Action = "backlog"    // 打开历史记录
+	ActionRollback  Action = "rollback"   // 回退到上一行
+	ActionHide      Action = "hide"       // 隐藏文本框
+	ActionMenu      Action = "menu"       // 用方向键操作快捷菜单
+	ActionUp        Action = "up"         // 菜单和选项向上
+	ActionDown      Action = "down"       // 菜单和选项向下
+	ActionConfirm   Action = "confirm"    // 确定
+	ActionCancel    Action = "cancel"     // 返回、关闭界面
+	ActionQuickSave Action = "quick_save" // 快速存档
+	ActionQuickLoad Action = "quick_load" // 快速读档
+)
+
+// actions 设置文件中可以绑定的所有动作
+var actions = map[Action]bool{
+	ActionAdvance: true, ActionSkip: true, ActionSkipHold: true, ActionAuto: true,
+	ActionBacklog: true, ActionRollback: true, ActionHide: true, ActionMenu: true,
+	ActionUp: true, ActionDown: true, ActionConfirm: true, ActionCancel: true,
+	ActionQuickSave: true, ActionQuickLoad: true,
+}
+
+// gamepadButtonNames 手柄按钮在设置文件中的名称，按标准手柄布局
+var gamepadButtonNames = map[string]ebiten.StandardGamepadButton{
+	"a":     ebiten.StandardGamepadButtonRightBottom,
+	"b":     ebiten.StandardGamepadButtonRightRight,
+	"x":     ebiten.StandardGamepadButtonRightLeft,
+	"y":     ebiten.StandardGamepadButtonRightTop,
+	"lb":    ebiten.StandardGamepadButtonFrontTopLeft,
+	"rb":    ebiten.StandardGamepadButtonFrontTopRight,
+	"lt":    ebiten.StandardGamepadButtonFrontBottomLeft,
+	"rt":    ebiten.StandardGamepadButtonFrontBottomRight,
+	"back":  ebiten.StandardGamepadButtonCenterLeft,
+	"start": ebiten.StandardGamepadButtonCenterRight,
+	"home":  ebiten.StandardGamepadButtonCenterCenter,
+	"ls":    ebiten.StandardGamepadButtonLeftStick,
+	"rs":    ebiten.StandardGamepadButtonRightStick,
+	"up":    ebiten.StandardGamepadButtonLeftTop,
+	"down":  ebiten.StandardGamepadButtonLeftBottom,
+	"left":  ebiten.StandardGamepadButtonLeftLeft,
+	"right": ebiten.StandardGamepadButtonLeftRight,
+}
+
+// InputMap 把键盘按键和手柄按钮映射到动作
+type InputMap struct {
+	keys     map[Action][]ebiten.Key
+	buttons  map[Action][]ebiten.StandardGamepadButton
+	gamepads []ebiten.GamepadID
+}
+
+// DefaultInputMap 返回默认按键：回车/空格推进，方向键选择，标准手柄 A 确定、B 返回
+func DefaultInputMap() *InputMap {
+	return &InputMap{
+		keys: map[Action][]ebiten.Key{
+			ActionAdvance:   {ebiten.KeyEnter, ebiten.KeySpace, ebiten.KeyNumpadEnter},
+			ActionSkip:      {ebiten.KeyTab},
+			ActionSkipHold:  {ebiten.KeyControl},
+			ActionAuto:      {ebiten.KeyA},
+			ActionBacklog:   {ebiten.KeyL},
+			ActionRollback:  {ebiten.KeyPageUp},
+			ActionHide:      {ebiten.KeyH},
+			ActionMenu:      {ebiten.KeyM},
+			ActionUp:        {ebiten.KeyArrowUp},
+			ActionDown:      {ebiten.KeyArrowDown},
+			ActionConfirm:   {ebiten.KeyEnter, ebiten.KeySpace, ebiten.KeyNumpadEnter},
+			ActionCancel:    {ebiten.KeyEscape, ebiten.KeyBackspace},
+			ActionQuickSave: {ebiten.KeyF5},
+			ActionQuickLoad: {ebiten.KeyF9},
+		},
+		buttons: map[Action][]ebiten.StandardGamepadButton{
+			ActionAdvance:  {ebiten.StandardGamepadButtonRightBottom},
+			ActionSkip:     {ebiten.StandardGamepadButtonFrontTopRight},
+			ActionSkipHold: {ebiten.StandardGamepadButtonFrontBottomRight},
+			ActionAuto:     {ebiten.StandardGamepadButtonRightTop},
+			ActionBacklog:  {ebiten.StandardGamepadButtonFrontTopLeft},
+			ActionRollback: {ebiten.StandardGamepadButtonFrontBottomLeft},
+			ActionHide:     {ebiten.StandardGamepadButtonRightLeft},
+			ActionMenu:     {ebiten.StandardGamepadButtonCenterRight},
+			ActionUp:       {ebiten.StandardGamepadButtonLeftTop},
+			ActionDown:     {ebiten.StandardGamepadButtonLeftBottom},
+			ActionConfirm:  {ebiten.StandardGamepadButtonRightBottom},
+			ActionCancel:   {ebiten.StandardGamepadButtonRightRight},
+		},
+	}
+}
+
+// Apply 用设置中的按键覆盖默认绑定，只替换设置里出现的动作，错误汇总后返回
+func (m *InputMap) Apply(keys, buttons map[string][]string) error {
+	var errs []string
+	for action, names := range keys {
+		if !actions[Action(action)] {
+			errs = append(errs, fmt.Sprintf("unknown action %q in key bindings", action))
+			continue
+		}
+		bound := make([]ebiten.Key, 0, len(names))
+		for _, name := range names {
+			var k ebiten.Key
+			if err := k.UnmarshalText([]byte(name)); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: unknown key %q", action, name))
+				continue
+			}
+			bound = append(bound, k)
+		}
+		m.keys[Action(action)] = bound
+	}
+	for action, names := range buttons {
+		if !actions[Action(action)] {
+			errs = append(errs, fmt.Sprintf("unknown action %q in gamepad bindings", action))
+			continue
+		}
+		bound := make([]ebiten.StandardGamepadButton, 0, len(names))
+		for _, name := range names {
+			b, ok := gamepadButtonNames[strings.ToLower(name)]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: unknown gamepad button %q", action, name))
+				continue
+			}
+			bound = append(bound, b)
+		}
+		m.buttons[Action(action)] = bound
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid input bindings: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Update 每帧刷新已连接的手柄
+func (m *InputMap) Update() {
+	m.gamepads = ebiten.AppendGamepadIDs(m.gamepads[:0])
+}
+
+// JustPressed 动作对应的任一按键或按钮在这一帧刚刚按下
+func (m *InputMap) JustPressed(a Action) bool {
+	for _, k := range m.keys[a] {
+		if inpututil.IsKeyJustPressed(k) {
+			return true
+		}
+	}
+	for _, id := range m.gamepads {
+		for _, b := range m.buttons[a] {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Pressed 动作对应的任一按键或按钮正被按住
+func (m *InputMap) Pressed(a Action) bool {
+	for _, k := range m.keys[a] {
+		if ebiten.IsKeyPressed(k) {
+			return true
+		}
+	}
+	for _, id := range m.gamepads {
+		for _, b := range m.buttons[a] {
+			if ebiten.IsStandardGamepadButtonPressed(id, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
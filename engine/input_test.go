@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInputMapApply(t *testing.T) {
+	m := DefaultInputMap()
+	err := m.Apply(
+		map[string][]string{
+			"advance": {"Z", "Nope"},
+			"jump":    {"J"},
+		},
+		map[string][]string{
+			"confim": {"a"},
+			"cancel": {"B", "c"},
+		},
+	)
+	if err == nil {
+		t.Fatal("Apply with unknown actions succeeded")
+	}
+	for _, msg := range []string{
+		`unknown action "jump" in key bindings`,
+		`unknown action "confim" in gamepad bindings`,
+		`advance: unknown key "Nope"`,
+		`cancel: unknown gamepad button "c"`,
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("error %q does not mention %q", err, msg)
+		}
+	}
+
+	// 有效的部分照常应用，未知的动作不会被登记
+	if got, want := m.keys[ActionAdvance], []ebiten.Key{ebiten.KeyZ}; !reflect.DeepEqual(got, want) {
+		t.Errorf("advance keys %v, want %v", got, want)
+	}
+	if got, want := m.buttons[ActionCancel], []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightRight}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cancel buttons %v, want %v", got, want)
+	}
+	if _, ok := m.keys["jump"]; ok {
+		t.Error("unknown action jump was bound")
+	}
+	if _, ok := m.buttons["confim"]; ok {
+		t.Error("unknown action confim was bound")
+	}
+	if got := m.keys[ActionConfirm]; len(got) != 3 {
+		t.Errorf("confirm keys %v changed, want the defaults", got)
+	}
+
+	if err := DefaultInputMap().Apply(map[string][]string{"quick_save": {"F1"}}, map[string][]string{"quick_load": {"back"}}); err != nil {
+		t.Errorf("Apply with valid bindings: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+	"image/color"
+)
+
+const (
+	quickMenuMargin  = 20
+	quickMenuSpacing = 24
+)
+
+// quickButton 快捷菜单上的一个文字按钮
+type quickButton struct {
+	label  string
+	rect   Rect
+	active func() bool // 按钮对应的模式是否开启，开启时高亮
+	click  func()
+}
+
+// QuickMenu 游戏画面右下角的快捷菜单：自动、快进和历史记录
+type QuickMenu struct {
+	Font    font.Face
+	buttons []quickButton
+	hovered int
+	focused int // 键盘焦点所在按钮，-1 表示快捷菜单没有焦点
+	width   int
+	height  int
+}
+
+func NewQuickMenu(e *Engine) *QuickMenu {
+	qm := &QuickMenu{
+		width:   e.Width,
+		height:  e.Height,
+		hovered: -1,
+		focused: -1,
+	}
+	qm.buttons = []quickButton{
+		{label: "Auto", active: func() bool { return e.autoMode }, click: func() { e.SetAutoMode(!e.autoMode) }},
+		{label: "Skip", active: func() bool { return e.skipMode }, click: func() { e.skipMode = !e.skipMode }},
+		{label: "Log", active: func() bool { return e.Backlog.IsOpen }, click: e.Backlog.Open},
+	}
+	return qm
+}
+
+// SetFont 设置字体并从右向左排列按钮
+func (qm *QuickMenu) SetFont(f font.Face) {
+	qm.Font = f
+	x := qm.width - quickMenuMargin
+	for i := len(qm.buttons) - 1; i >= 0; i-- {
+		bounds := text.BoundString(f, qm.buttons[i].label)
+		x -= bounds.Dx()
+		qm.buttons[i].rect = Rect{
+			X:      x,
+			Y:      qm.height - quickMenuMargin - bounds.Dy(),
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+		}
+		x -= quickMenuSpacing
+	}
+}
+
+// HandleInput 处理悬停和点击，点中按钮时返回 true，这次点击不再用于推进文本
+func (qm *QuickMenu) HandleInput() bool {
+	if qm.Font == nil {
+		return false
+	}
+	x, y := ebiten.CursorPosition()
+	qm.hovered = -1
+	for i, b := range qm.buttons {
+		r := b.rect
+		if x >= r.X && x <= r.X+r.Width && y >= r.Y && y <= r.Y+r.Height {
+			qm.hovered = i
+			break
+		}
+	}
+	if qm.hovered >= 0 && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		qm.buttons[qm.hovered].click()
+		return true
+	}
+	return false
+}
+
+// ToggleFocus 让快捷菜单获得或失去键盘焦点
+func (qm *QuickMenu) ToggleFocus() {
+	if qm.focused >= 0 {
+		qm.focused = -1
+	} else {
+		qm.focused = 0
+	}
+}
+
+func (qm *QuickMenu) Focused() bool {
+	return qm.focused >= 0
+}
+
+// HandleKeys 在快捷菜单有焦点时用上下键切换按钮，确定键按下按钮，返回键交还焦点
+func (qm *QuickMenu) HandleKeys(input *InputMap) {
+	n := len(qm.buttons)
+	switch {
+	case input.JustPressed(ActionUp):
+		qm.focused = (qm.focused + n - 1) % n
+	case input.JustPressed(ActionDown):
+		qm.focused = (qm.focused + 1) % n
+	case input.JustPressed(ActionConfirm):
+		qm.buttons[qm.focused].click()
+	case input.JustPressed(ActionCancel):
+		qm.focused = -1
+	}
+}
+
+func (qm *QuickMenu) Draw(screen *ebiten.Image) {
+	if qm.Font == nil {
+		return
+	}
+	for i, b := range qm.buttons {
+		textColor := color.Color(color.RGBA{200, 200, 200, 255})
+		switch {
+		case b.active():
+			textColor = color.RGBA{255, 220, 0, 255}
+		case i == qm.hovered || i == qm.focused:
+			textColor = color.White
+		}
+		label, x := b.label, b.rect.X
+		if i == qm.focused {
+			label = "> " + label
+			x -= text.BoundString(qm.Font, "> ").Dx()
+		}
+		baseline := b.rect.Y + b.rect.Height
+		text.Draw(screen, label, qm.Font, x+1, baseline+1, color.Black)
+		text.Draw(screen, label, qm.Font, x, baseline, textColor)
+	}
+}
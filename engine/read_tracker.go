@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const readTrackerFileName = "read.json"
+
+// ReadTracker 记录玩家读过的文本行，按模块名和源文件行号区分，所有存档共用
+type ReadTracker struct {
+	lines map[string]map[int]bool
+	dirty bool
+	path  string // 为空时不写入磁盘
+}
+
+func NewReadTracker() *ReadTracker {
+	return &ReadTracker{lines: make(map[string]map[int]bool)}
+}
+
+// LoadReadTracker 读取已读记录，文件不存在时返回空记录，之后 Save 会写到同一路径
+func LoadReadTracker(path string) (*ReadTracker, error) {
+	rt := NewReadTracker()
+	rt.path = path
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rt, nil
+	}
+	if err != nil {
+		return rt, fmt.Errorf("failed to read read-line data: %v", err)
+	}
+	var stored map[string][]int
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return rt, fmt.Errorf("failed to decode read-line data: %v", err)
+	}
+	for module, lines := range stored {
+		set := make(map[int]bool, len(lines))
+		for _, line := range lines {
+			set[line] = true
+		}
+		rt.lines[module] = set
+	}
+	return rt, nil
+}
+
+func (rt *ReadTracker) IsRead(module string, line int) bool {
+	return rt.lines[module][line]
+}
+
+func (rt *ReadTracker) MarkRead(module string, line int) {
+	set, ok := rt.lines[module]
+	if !ok {
+		set = make(map[int]bool)
+		rt.lines[module] = set
+	}
+	if !set[line] {
+		set[line] = true
+		rt.dirty = true
+	}
+}
+
+// Save 有新的已读行时写回文件，每个模块的行号排序后保存
+func (rt *ReadTracker) Save() error {
+	if rt.path == "" || !rt.dirty {
+		return nil
+	}
+	stored := make(map[string][]int, len(rt.lines))
+	for module, set := range rt.lines {
+		lines := make([]int, 0, len(set))
+		for line := range set {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		stored[module] = lines
+	}
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode read-line data: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rt.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := writeFileAtomic(rt.path, raw); err != nil {
+		return fmt.Errorf("failed to write read-line data: %v", err)
+	}
+	rt.dirty = false
+	return nil
+}
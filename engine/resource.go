@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/golang/freetype/truetype"
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/font"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 没有被引用的资源最多保留的总字节数，超出后按最久未使用的顺序淘汰
+const defaultCacheBudget = 256 << 20
+
+// resourceEntry 一个缓存的资源。decoded 是后台预加载解码出的图片，
+// 第一次在主线程使用时才创建 ebiten.Image
+type resourceEntry struct {
+	key      string
+	image    *ebiten.Image
+	decoded  image.Image
+	face     font.Face
+	data     []byte
+	size     int64
+	refs     int
+	lastUsed uint64
+}
+
+// ResourceManager 按资源路径缓存解码后的图片、遮罩、字体和音频文件并计算引用。
+// 引用归零的资源先留在缓存中，超出预算后按最久未使用淘汰，淘汰时立即释放图片占用的显存。
+// 读文件和解码不持有锁，不会阻塞主循环中对其他资源的访问
+type ResourceManager struct {
+	mutex   sync.Mutex
+	entries map[string]*resourceEntry
+	loading map[string]bool // 正在后台预加载的资源
+	budget  int64
+	clock   uint64
+	assets  *Assets
+}
+
+func NewResourceManager(assets *Assets) *ResourceManager {
+	return &ResourceManager{
+		entries: make(map[string]*resourceEntry),
+		loading: make(map[string]bool),
+		budget:  defaultCacheBudget,
+		assets:  assets,
+	}
+}
+
+func imageKey(path string) string { return "image:" + path }
+func dataKey(path string) string  { return "data:" + path }
+func fontKey(path string, size, dpi float64) string {
+	return fmt.Sprintf("font:%s@%g/%g", path, size, dpi)
+}
+
+// touch 记录一次使用，调用方持有锁
+func (rm *ResourceManager) touch(entry *resourceEntry) {
+	rm.clock++
+	entry.lastUsed = rm.clock
+}
+
+// Image 返回图片并增加一次引用，不再使用时调用 ReleaseImage
+func (rm *ResourceManager) Image(path string) (*ebiten.Image, error) {
+	path = rm.assets.Name(path)
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	key := imageKey(path)
+	entry, ok := rm.entries[key]
+	if !ok {
+		rm.mutex.Unlock()
+		decoded, err := rm.decodeImage(path)
+		rm.mutex.Lock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load image %s: %v", path, err)
+		}
+		// 解码期间同一张图片可能已经由预加载登记
+		if entry, ok = rm.entries[key]; !ok {
+			entry = &resourceEntry{key: key, decoded: decoded, size: imageSize(decoded)}
+			rm.entries[key] = entry
+		}
+	}
+	if entry.image == nil {
+		entry.image = ebiten.NewImageFromImage(entry.decoded)
+		entry.decoded = nil
+	}
+	entry.refs++
+	rm.touch(entry)
+	return entry.image, nil
+}
+
+// ReleaseImage 减少一次图片引用
+func (rm *ResourceManager) ReleaseImage(path string) {
+	rm.release(imageKey(rm.assets.Name(path)))
+}
+
+func (rm *ResourceManager) release(key string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	entry, ok := rm.entries[key]
+	if !ok || entry.refs == 0 {
+		return
+	}
+	entry.refs--
+	if entry.refs == 0 {
+		rm.evict()
+	}
+}
+
+// Data 返回文件的原始内容，用于音频等需要每次重新解码的资源，不计引用
+func (rm *ResourceManager) Data(path string) ([]byte, error) {
+	path = rm.assets.Name(path)
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	key := dataKey(path)
+	if entry, ok := rm.entries[key]; ok {
+		rm.touch(entry)
+		return entry.data, nil
+	}
+	rm.mutex.Unlock()
+	data, err := rm.assets.ReadFile(path)
+	rm.mutex.Lock()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := rm.entries[key]
+	if !ok {
+		entry = &resourceEntry{key: key, data: data, size: int64(len(data))}
+		rm.entries[key] = entry
+	}
+	rm.touch(entry)
+	rm.evict()
+	return entry.data, nil
+}
+
+// Font 返回指定字号的字体，字体一直保留在缓存中
+func (rm *ResourceManager) Font(path string, size, dpi float64) (font.Face, error) {
+	path = rm.assets.Name(path)
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	key := fontKey(path, size, dpi)
+	if entry, ok := rm.entries[key]; ok {
+		rm.touch(entry)
+		return entry.face, nil
+	}
+	rm.mutex.Unlock()
+	parsedFont, err := rm.parseFont(path)
+	rm.mutex.Lock()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := rm.entries[key]
+	if !ok {
+		face := truetype.NewFace(parsedFont, &truetype.Options{
+			Size:    size,
+			DPI:     dpi,
+			Hinting: font.HintingFull,
+		})
+		entry = &resourceEntry{key: key, face: face, refs: 1}
+		rm.entries[key] = entry
+	}
+	rm.touch(entry)
+	return entry.face, nil
+}
+
+func (rm *ResourceManager) parseFont(path string) (*truetype.Font, error) {
+	fontBytes, err := rm.assets.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font file: %v", err)
+	}
+	parsedFont, err := truetype.Parse(fontBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", err)
+	}
+	return parsedFont, nil
+}
+
+// evict 未被引用的资源超出预算时，从最久未使用的开始淘汰，调用方持有锁
+func (rm *ResourceManager) evict() {
+	var unused []*resourceEntry
+	var total int64
+	for _, entry := range rm.entries {
+		if entry.refs == 0 {
+			unused = append(unused, entry)
+			total += entry.size
+		}
+	}
+	if total <= rm.budget {
+		return
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].lastUsed < unused[j].lastUsed
+	})
+	for _, entry := range unused {
+		if total <= rm.budget {
+			break
+		}
+		delete(rm.entries, entry.key)
+		if entry.image != nil {
+			entry.image.Deallocate() // 没有引用的图片不会再被绘制
+		}
+		total -= entry.size
+	}
+}
+
+// Preload 在后台协程中读取并解码资源，不阻塞主循环。图片只解码到内存，
+// 第一次显示时再上传为 ebiten.Image
+func (rm *ResourceManager) Preload(paths []string) {
+	var todo []string
+	rm.mutex.Lock()
+	for _, path := range paths {
+		path = rm.assets.Name(path)
+		key := preloadKey(path)
+		if _, ok := rm.entries[key]; ok || rm.loading[key] {
+			continue
+		}
+		rm.loading[key] = true
+		todo = append(todo, path)
+	}
+	rm.mutex.Unlock()
+	if len(todo) == 0 {
+		return
+	}
+
+	go func() {
+		for _, path := range todo {
+			key := preloadKey(path)
+			entry := &resourceEntry{key: key}
+			var err error
+			if strings.HasPrefix(key, "image:") {
+				if entry.decoded, err = rm.decodeImage(path); err == nil {
+					entry.size = imageSize(entry.decoded)
+				}
+			} else if entry.data, err = rm.assets.ReadFile(path); err == nil {
+				entry.size = int64(len(entry.data))
+			}
+
+			rm.mutex.Lock()
+			delete(rm.loading, key)
+			if err != nil {
+				log.Printf("Failed to preload %s: %v", path, err)
+			} else if _, ok := rm.entries[key]; !ok {
+				// 主线程可能已经同步加载过，这时丢弃预加载的结果
+				rm.entries[key] = entry
+				rm.touch(entry)
+				rm.evict()
+			}
+			rm.mutex.Unlock()
+		}
+	}()
+}
+
+// preloadKey 按扩展名区分图片和其他文件
+func preloadKey(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+		return imageKey(path)
+	}
+	return dataKey(path)
+}
+
+func (rm *ResourceManager) decodeImage(path string) (image.Image, error) {
+	file, err := rm.assets.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+func imageSize(img image.Image) int64 {
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
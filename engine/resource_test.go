@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+// 16x16 的图片在缓存中按 1024 字节计算
+const testImageSize = 16 * 16 * 4
+
+func newTestResources(t *testing.T, budget int64, names ...string) *ResourceManager {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16))); err != nil {
+			t.Fatal(err)
+		}
+		fsys[name] = &fstest.MapFile{Data: buf.Bytes()}
+	}
+	rm := NewResourceManager(&Assets{fsys: fsys, root: "./resource"})
+	rm.budget = budget
+	return rm
+}
+
+// cachedRefs 返回图片是否在缓存中及其引用数
+func cachedRefs(rm *ResourceManager, path string) (refs int, ok bool) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	entry, ok := rm.entries[imageKey(path)]
+	if !ok {
+		return 0, false
+	}
+	return entry.refs, true
+}
+
+func TestResourceRefCount(t *testing.T) {
+	rm := newTestResources(t, 0, "bg/room.png")
+	a, err := rm.Image("bg/room.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 带资源根目录前缀的旧路径指向同一个缓存
+	b, err := rm.Image("./resource/bg/room.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("the same image was loaded twice")
+	}
+	if refs, _ := cachedRefs(rm, "bg/room.png"); refs != 2 {
+		t.Errorf("refs = %d after two loads, want 2", refs)
+	}
+
+	rm.ReleaseImage("bg/room.png")
+	if refs, ok := cachedRefs(rm, "bg/room.png"); !ok || refs != 1 {
+		t.Errorf("refs = %d (cached %v) after one release, want 1", refs, ok)
+	}
+	rm.ReleaseImage("bg/room.png")
+	if _, ok := cachedRefs(rm, "bg/room.png"); ok {
+		t.Error("unreferenced image kept with a zero budget")
+	}
+	// 多余的释放不会出错
+	rm.ReleaseImage("bg/room.png")
+
+	if _, err := rm.Image("bg/missing.png"); err == nil {
+		t.Error("loading a missing image succeeded")
+	}
+}
+
+func TestResourceEvictionOrder(t *testing.T) {
+	rm := newTestResources(t, 2*testImageSize, "a.png", "b.png", "c.png", "d.png")
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		if _, err := rm.Image(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		rm.ReleaseImage(name)
+	}
+	// 超出预算时先淘汰最久未使用的 a
+	check := func(want map[string]bool) {
+		t.Helper()
+		for name, cached := range want {
+			if _, ok := cachedRefs(rm, name); ok != cached {
+				t.Errorf("%s cached = %v, want %v", name, ok, cached)
+			}
+		}
+	}
+	check(map[string]bool{"a.png": false, "b.png": true, "c.png": true})
+
+	// 再次使用 b 后，最久未使用的变成 c
+	if _, err := rm.Image("b.png"); err != nil {
+		t.Fatal(err)
+	}
+	rm.ReleaseImage("b.png")
+	if _, err := rm.Image("d.png"); err != nil {
+		t.Fatal(err)
+	}
+	rm.ReleaseImage("d.png")
+	check(map[string]bool{"b.png": true, "c.png": false, "d.png": true})
+}
+
+func TestResourcePinnedWhileReferenced(t *testing.T) {
+	rm := newTestResources(t, 0, "a.png", "b.png")
+	a, err := rm.Image("a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rm.Image("b.png"); err != nil {
+		t.Fatal(err)
+	}
+	rm.ReleaseImage("b.png")
+
+	// 被引用的图片即使超出预算也不会被淘汰
+	if refs, ok := cachedRefs(rm, "a.png"); !ok || refs != 1 {
+		t.Errorf("referenced image evicted (refs %d, cached %v)", refs, ok)
+	}
+	if _, ok := cachedRefs(rm, "b.png"); ok {
+		t.Error("unreferenced image kept with a zero budget")
+	}
+	if again, err := rm.Image("a.png"); err != nil || again != a {
+		t.Errorf("reloading a referenced image returned a different image (%v)", err)
+	}
+}
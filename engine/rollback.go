@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"log"
+)
+
+const defaultRollbackDepth = 100
+
+// rollbackPoint 回退点：显示某行文本或某个选择支时的完整状态。
+// 历史记录只保存当时的条数，回退时截掉之后新增的部分
+type rollbackPoint struct {
+	state      *SaveData
+	backlogPos int
+}
+
+// rollbackRing 固定容量的回退点环形缓冲区，满了以后覆盖最旧的记录
+type rollbackRing struct {
+	points []rollbackPoint
+	start  int
+	size   int
+}
+
+func (r *rollbackRing) push(p rollbackPoint, depth int) {
+	if depth <= 0 {
+		r.clear()
+		return
+	}
+	if len(r.points) != depth {
+		r.resize(depth)
+	}
+	if r.size < depth {
+		r.points[(r.start+r.size)%depth] = p
+		r.size++
+		return
+	}
+	r.points[r.start] = p
+	r.start = (r.start + 1) % depth
+}
+
+// resize 调整容量，保留最新的记录
+func (r *rollbackRing) resize(depth int) {
+	points := make([]rollbackPoint, depth)
+	keep := r.size
+	if keep > depth {
+		keep = depth
+	}
+	for i := 0; i < keep; i++ {
+		points[i] = r.points[(r.start+r.size-keep+i)%len(r.points)]
+	}
+	r.points, r.start, r.size = points, 0, keep
+}
+
+func (r *rollbackRing) pop() (rollbackPoint, bool) {
+	if r.size == 0 {
+		return rollbackPoint{}, false
+	}
+	r.size--
+	i := (r.start + r.size) % len(r.points)
+	p := r.points[i]
+	r.points[i] = rollbackPoint{}
+	return p, true
+}
+
+func (r *rollbackRing) top() (rollbackPoint, bool) {
+	if r.size == 0 {
+		return rollbackPoint{}, false
+	}
+	return r.points[(r.start+r.size-1)%len(r.points)], true
+}
+
+func (r *rollbackRing) clear() {
+	r.points, r.start, r.size = nil, 0, 0
+}
+
+// checkpoint 在显示文本或选择支后记录回退点
+func (se *ScriptEngine) checkpoint() {
+	se.history.push(rollbackPoint{
+		state:      se.engine.captureScene(),
+		backlogPos: se.engine.Backlog.Count(),
+	}, se.engine.Settings.RollbackDepth)
+}
+
+// CanRollback 是否还有可以回退到的上一行
+func (se *ScriptEngine) CanRollback() bool {
+	return se.history.size > 1
+}
+
+// Rollback 回到上一行文本或上一个选择支，回到选择支时可以重新选择
+func (se *ScriptEngine) Rollback() bool {
+	if !se.CanRollback() {
+		return false
+	}
+	se.history.pop() // 当前所在的行
+	p, _ := se.history.top()
+
+	// restoreState 会用回退点中空的历史覆盖当前历史，先保留下来再截断
+	backlog := se.engine.Backlog
+	entries, count := backlog.Entries, backlog.count
+	if err := se.engine.restoreState(p.state); err != nil {
+		log.Printf("回退失败: %v", err)
+		return false
+	}
+	backlog.Entries, backlog.count = entries, count
+	backlog.Truncate(p.backlogPos)
+	log.Printf("回退到: %s:%d", p.state.Script, p.state.Line)
+	return true
+}
+
+// ClearHistory 清空回退记录，读档和开始新游戏时调用
+func (se *ScriptEngine) ClearHistory() {
+	se.history.clear()
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"RenGO/script"
+	"reflect"
+	"testing"
+)
+
+func pushLines(r *rollbackRing, from, to, depth int) {
+	for line := from; line <= to; line++ {
+		r.push(rollbackPoint{state: &SaveData{Line: line}}, depth)
+	}
+}
+
+// popLines 依次弹出所有回退点，返回它们的行号，最新的在前
+func popLines(r *rollbackRing) []int {
+	var lines []int
+	for {
+		p, ok := r.pop()
+		if !ok {
+			return lines
+		}
+		lines = append(lines, p.state.Line)
+	}
+}
+
+func TestRollbackRingDepth(t *testing.T) {
+	var r rollbackRing
+	pushLines(&r, 1, 10, 4)
+	if r.size != 4 || len(r.points) != 4 {
+		t.Fatalf("size %d, capacity %d after 10 pushes with depth 4", r.size, len(r.points))
+	}
+	if top, _ := r.top(); top.state.Line != 10 {
+		t.Errorf("top is line %d, want 10", top.state.Line)
+	}
+	if got, want := popLines(&r), []int{10, 9, 8, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+	if _, ok := r.top(); ok {
+		t.Error("top of an empty ring succeeded")
+	}
+}
+
+func TestRollbackRingResize(t *testing.T) {
+	var r rollbackRing
+	pushLines(&r, 1, 6, 8)
+
+	// 缩小容量时保留最新的记录
+	pushLines(&r, 7, 7, 3)
+	if r.size != 3 {
+		t.Fatalf("size %d after shrinking to 3", r.size)
+	}
+	// 扩大容量后继续追加，不丢失已有记录
+	pushLines(&r, 8, 9, 5)
+	if got, want := popLines(&r), []int{9, 8, 7, 6, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+
+	// 深度为 0 时禁用回退并清空记录
+	pushLines(&r, 1, 3, 5)
+	pushLines(&r, 4, 4, 0)
+	if r.size != 0 {
+		t.Errorf("size %d after disabling rollback", r.size)
+	}
+}
+
+func TestRollbackToChoice(t *testing.T) {
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Play(nil); err != nil {
+		t.Fatal(err)
+	}
+	choicePos := r.Position()
+	if err := r.Choose(0); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Text(); got != "Hello!" {
+		t.Fatalf("text after the first option %q, want %q", got, "Hello!")
+	}
+
+	// 回到选择支时撤销选项之后的变量和好感度变化
+	if err := r.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.WaitingForChoice() {
+		t.Fatalf("not waiting for a choice after rolling back, at %s", r.Position())
+	}
+	if got := r.Position(); got != choicePos {
+		t.Errorf("position after rolling back %s, want %s", got, choicePos)
+	}
+	if _, ok := r.Variable("met"); ok {
+		t.Error("variable met is still set after rolling back")
+	}
+	if got := r.Affection("alice"); got != 0 {
+		t.Errorf("affection alice = %d after rolling back, want 0", got)
+	}
+
+	if err := r.Choose(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Text(); got != "..." {
+		t.Errorf("text after the other option %q, want %q", got, "...")
+	}
+	if got, ok := r.Variable("met"); !ok || got != script.BoolVal(false) {
+		t.Errorf("variable met = %v (set %v), want false", got, ok)
+	}
+	if got := r.Affection("alice"); got != 0 {
+		t.Errorf("affection alice = %d, want 0", got)
+	}
+	if err := r.Play([]int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Text(), "Bad ending."; got != want {
+		t.Errorf("ending %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,505 @@
+package engine
+
+import (
+	"RenGO/script"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	saveVersion   = 2
+	quickSaveSlot = 0
+)
+
+// CharacterState 保存一个显示中的立绘，组合立绘只记录选中的部件
+type CharacterState struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path,omitempty"`
+	Parts     map[string]string `json:"parts,omitempty"`
+	Slot      string            `json:"slot"`
+	X         float64           `json:"x"`
+	Y         float64           `json:"y"`
+	Transform *Transform        `json:"transform,omitempty"`
+}
+
+// LayerState 保存单个图层的显示内容
+type LayerState struct {
+	ImagePath  string           `json:"image_path,omitempty"`
+	Transform  *Transform       `json:"transform,omitempty"` // 图层图片的动画属性
+	Characters []CharacterState `json:"characters,omitempty"`
+	Visible    bool             `json:"visible"`
+	ZIndex     int              `json:"z_index"`
+
+	// 版本 1 的存档每个图层只有一个立绘，只在读取旧存档时使用
+	CharName string `json:"char_name,omitempty"`
+	CharPath string `json:"char_path,omitempty"`
+}
+
+// captureLayer 收集图层当前的显示内容。完全透明的图片和立绘只会来自淡出动画，
+// 淡出结束时它们会被移除，所以按已移除保存
+func captureLayer(layer *Layer) LayerState {
+	state := LayerState{
+		Visible: layer.Visible,
+		ZIndex:  layer.ZIndex,
+	}
+	if layer.ImageDisplay.Alpha != 0 {
+		state.ImagePath = layer.ImagePath
+		state.Transform = layer.ImageDisplay.Transform.saved()
+	}
+	for _, char := range layer.CharDisplay.Characters() {
+		if char.Alpha == 0 {
+			continue
+		}
+		state.Characters = append(state.Characters, CharacterState{
+			Name:      char.Name,
+			Path:      char.Path,
+			Parts:     char.Parts,
+			Slot:      char.Slot,
+			X:         char.X,
+			Y:         char.Y,
+			Transform: char.Transform.saved(),
+		})
+	}
+	return state
+}
+
+// SaveData 是一次存档的完整快照
+type SaveData struct {
+	Version           int                       `json:"version"`
+	SavedAt           time.Time                 `json:"saved_at"`
+	Script            string                    `json:"script"`
+	Line              int                       `json:"line"`
+	PendingJump       string                    `json:"pending_jump,omitempty"`
+	CallStack         []ReturnAddress           `json:"call_stack,omitempty"`
+	Variables         map[string]script.Value   `json:"variables"`
+	Affection         map[string]int            `json:"affection"`
+	Choices           []script.Choice           `json:"choices,omitempty"`
+	WaitingForChoice  bool                      `json:"waiting_for_choice"`
+	WaitingForInput   bool                      `json:"waiting_for_input"`
+	CurrentText       string                    `json:"current_text"`
+	Layers            []LayerState              `json:"layers"`
+	CurrentImageLayer int                       `json:"current_image_layer"`
+	Camera            *Camera                   `json:"camera,omitempty"`  // 镜头的缩放、位置和色调
+	Shaders           map[string][]ShaderEffect `json:"shaders,omitempty"` // 按目标（图层编号或 screen）记录的着色器
+	BGM               *BGMState                 `json:"bgm,omitempty"`
+	Backlog           []BacklogEntry            `json:"backlog,omitempty"`
+}
+
+// SlotInfo 描述一个已存在的存档位
+type SlotInfo struct {
+	Slot    int
+	SavedAt time.Time
+	Text    string
+}
+
+// SaveManager 负责把存档读写到磁盘上的编号存档位
+type SaveManager struct {
+	dir string
+}
+
+func NewSaveManager(dir string) *SaveManager {
+	return &SaveManager{dir: dir}
+}
+
+func (sm *SaveManager) slotPath(slot int) string {
+	return filepath.Join(sm.dir, fmt.Sprintf("slot%03d.json", slot))
+}
+
+// Save 将存档写入指定存档位，先写临时文件再重命名，避免写到一半损坏旧存档
+func (sm *SaveManager) Save(slot int, data *SaveData) error {
+	if slot < 0 {
+		return fmt.Errorf("invalid save slot %d", slot)
+	}
+	if err := os.MkdirAll(sm.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create save directory: %v", err)
+	}
+
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode save data: %v", err)
+	}
+
+	if err := writeFileAtomic(sm.slotPath(slot), raw); err != nil {
+		return fmt.Errorf("failed to write save file: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，写到一半失败时不会破坏原文件
+func writeFileAtomic(path string, raw []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Load 读取指定存档位
+func (sm *SaveManager) Load(slot int) (*SaveData, error) {
+	raw, err := os.ReadFile(sm.slotPath(slot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read save slot %d: %v", slot, err)
+	}
+
+	data := &SaveData{}
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, fmt.Errorf("failed to decode save slot %d: %v", slot, err)
+	}
+	if data.Version > saveVersion {
+		return nil, fmt.Errorf("save slot %d was written by a newer version (%d)", slot, data.Version)
+	}
+	return data, nil
+}
+
+func (sm *SaveManager) Exists(slot int) bool {
+	_, err := os.Stat(sm.slotPath(slot))
+	return err == nil
+}
+
+func (sm *SaveManager) Delete(slot int) error {
+	err := os.Remove(sm.slotPath(slot))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete save slot %d: %v", slot, err)
+	}
+	return nil
+}
+
+// Slots 列出所有已存在的存档位，按编号排序
+func (sm *SaveManager) Slots() ([]SlotInfo, error) {
+	entries, err := os.ReadDir(sm.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read save directory: %v", err)
+	}
+
+	var slots []SlotInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "slot") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		slot, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "slot"), ".json"))
+		if err != nil {
+			continue
+		}
+		data, err := sm.Load(slot)
+		if err != nil {
+			log.Printf("Skipping broken save slot %d: %v", slot, err)
+			continue
+		}
+		slots = append(slots, SlotInfo{Slot: slot, SavedAt: data.SavedAt, Text: data.CurrentText})
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Slot < slots[j].Slot
+	})
+	return slots, nil
+}
+
+// Latest 返回最近一次写入的存档位
+func (sm *SaveManager) Latest() (int, bool) {
+	slots, err := sm.Slots()
+	if err != nil || len(slots) == 0 {
+		return 0, false
+	}
+	latest := slots[0]
+	for _, s := range slots[1:] {
+		if s.SavedAt.After(latest.SavedAt) {
+			latest = s
+		}
+	}
+	return latest.Slot, true
+}
+
+// captureState 收集脚本引擎和图层的当前状态
+func (e *Engine) captureState() *SaveData {
+	data := e.captureScene()
+	data.Backlog = append([]BacklogEntry(nil), e.Backlog.Entries...)
+	return data
+}
+
+// captureScene 收集除历史记录以外的状态，回退点不复制历史记录，只记下当时的条数
+func (e *Engine) captureScene() *SaveData {
+	se := e.ScriptEngine
+	data := &SaveData{
+		Version:           saveVersion,
+		SavedAt:           time.Now(),
+		Script:            se.scriptFile,
+		Line:              se.currentLine,
+		PendingJump:       se.pendingJump,
+		CallStack:         append([]ReturnAddress(nil), se.callStack...),
+		Variables:         make(map[string]script.Value, len(se.variables)),
+		Affection:         make(map[string]int, len(se.affection)),
+		Choices:           append([]script.Choice(nil), se.choicesToShow...),
+		WaitingForChoice:  se.waitingForChoice,
+		WaitingForInput:   se.waitingForInput,
+		CurrentText:       se.currentText,
+		Layers:            make([]LayerState, len(e.Layers)),
+		CurrentImageLayer: e.CurrentImageLayer,
+		BGM:               e.Audio.BGM(),
+	}
+	for k, v := range se.variables {
+		data.Variables[k] = v
+	}
+	for k, v := range se.affection {
+		data.Affection[k] = v
+	}
+	// 进行中的动画按完成后的状态保存
+	e.Tweens.atEnd(func() {
+		for i, layer := range e.Layers {
+			data.Layers[i] = captureLayer(layer)
+		}
+		data.Camera = e.Camera.saved()
+		data.Shaders = e.PostEffects.saved()
+	})
+	return data
+}
+
+// restoreCharacter 按存档中的位置和显示属性还原立绘
+func (e *Engine) restoreCharacter(layer *Layer, char CharacterState) error {
+	if char.Parts == nil {
+		if err := layer.CharDisplay.Show(char.Name, char.Path, char.Slot, char.X, char.Y); err != nil {
+			return err
+		}
+	} else {
+		def, err := e.characterDef(char.Name)
+		if err != nil {
+			return err
+		}
+		// 定义文件可能已经改过，去掉不存在的部件
+		parts, err := def.Select(char.Parts, nil)
+		if err != nil {
+			return err
+		}
+		if err := layer.CharDisplay.ShowParts(def, parts, char.Slot, char.X, char.Y); err != nil {
+			return err
+		}
+	}
+	if char.Transform != nil {
+		shown, _ := layer.CharDisplay.Get(char.Name)
+		shown.Transform = *char.Transform
+	}
+	return nil
+}
+
+// prepareRestore 检查存档能否还原，并预先加载存档用到的图片。
+// 返回存档所在的脚本和释放预加载引用的函数；出错时已加载的图片已经释放
+func (e *Engine) prepareRestore(data *SaveData) (*script.Script, func(), error) {
+	var loaded []string
+	release := func() {
+		for _, p := range loaded {
+			e.Resources.ReleaseImage(p)
+		}
+	}
+	preload := func(paths ...string) error {
+		if e.headless {
+			return nil // 无窗口模式下不加载图片
+		}
+		for _, p := range paths {
+			if _, err := e.Resources.Image(p); err != nil {
+				return err
+			}
+			loaded = append(loaded, p)
+		}
+		return nil
+	}
+	fail := func(err error) (*script.Script, func(), error) {
+		release()
+		return nil, nil, err
+	}
+
+	current, err := e.ScriptEngine.registry.Get(data.Script)
+	if err != nil {
+		return fail(err)
+	}
+	if data.Line < 0 || data.Line > len(current.Nodes) {
+		return fail(fmt.Errorf("save points to line %d outside of %s", data.Line, data.Script))
+	}
+	for _, ret := range data.CallStack {
+		caller, err := e.ScriptEngine.registry.Get(ret.Script)
+		if err != nil {
+			return fail(err)
+		}
+		if ret.Line < 0 || ret.Line > len(caller.Nodes) {
+			return fail(fmt.Errorf("save returns to line %d outside of %s", ret.Line, ret.Script))
+		}
+	}
+
+	for i := 0; i < len(e.Layers) && i < len(data.Layers); i++ {
+		state := data.Layers[i]
+		if state.ImagePath != "" {
+			if err := preload(state.ImagePath); err != nil {
+				return fail(err)
+			}
+		}
+		for _, char := range state.Characters {
+			if char.Parts == nil {
+				if err := preload(char.Path); err != nil {
+					return fail(err)
+				}
+				continue
+			}
+			def, err := e.characterDef(char.Name)
+			if err != nil {
+				return fail(err)
+			}
+			parts, err := def.Select(char.Parts, nil)
+			if err != nil {
+				return fail(err)
+			}
+			for _, part := range def.Layers(parts) {
+				if err := preload(part.Image); err != nil {
+					return fail(err)
+				}
+			}
+		}
+		if state.CharName != "" {
+			if err := preload(state.CharPath); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	return current, release, nil
+}
+
+// restoreState 用存档内容还原脚本引擎和图层。先检查存档并加载图片，
+// 全部成功后才改动引擎状态，读档失败时游戏保持原样
+func (e *Engine) restoreState(data *SaveData) error {
+	se := e.ScriptEngine
+
+	current, release, err := e.prepareRestore(data)
+	if err != nil {
+		return err
+	}
+	defer release() // 图层和立绘显示后各自持有引用
+
+	se.script = current
+	se.scriptFile = current.Name
+	se.currentLine = data.Line
+	se.pendingJump = data.PendingJump
+	se.callStack = append([]ReturnAddress(nil), data.CallStack...)
+
+	se.variables = make(map[string]script.Value, len(data.Variables))
+	for k, v := range data.Variables {
+		se.variables[k] = v
+	}
+	se.affection = make(map[string]int, len(data.Affection))
+	for k, v := range data.Affection {
+		se.affection[k] = v
+	}
+
+	for i, layer := range e.Layers {
+		e.ClearLayer(i)
+		if i >= len(data.Layers) {
+			continue
+		}
+		state := data.Layers[i]
+		if state.ImagePath != "" {
+			if err := e.showLayerImage(layer, "background", state.ImagePath); err != nil {
+				return err
+			}
+		}
+		if state.Transform != nil {
+			layer.ImageDisplay.Transform = *state.Transform
+		}
+		for _, char := range state.Characters {
+			if err := e.restoreCharacter(layer, char); err != nil {
+				return err
+			}
+		}
+		if state.CharName != "" {
+			// 与脚本中旧版 @chara 的处理一致
+			name, slot := e.legacyCharacter(state.CharName, state.CharPath)
+			if err := e.ShowCharacter(i, name, state.CharPath, slot); err != nil {
+				return err
+			}
+		}
+		layer.Visible = state.Visible
+		layer.ZIndex = state.ZIndex
+	}
+	e.CurrentImageLayer = data.CurrentImageLayer
+
+	e.Transitions.Clear()
+	e.Tweens.Clear()
+	e.Camera.restore(data.Camera)
+	e.restoreShaders(data.Shaders)
+
+	// 音效和语音不存档，读档时 BGM 换成存档时的曲子
+	e.Audio.StopSE()
+	e.Audio.StopVoice()
+	if data.BGM != nil {
+		if err := e.Audio.PlayBGM(data.BGM.Path, data.BGM.Volume, 0, data.BGM.Loop); err != nil {
+			log.Printf("Failed to restore BGM: %v", err)
+		}
+	} else {
+		e.Audio.StopBGM(0)
+	}
+
+	e.Backlog.Restore(data.Backlog)
+
+	se.currentText = data.CurrentText
+	if data.CurrentText != "" {
+		e.TextDisplay.SetText(data.CurrentText)
+		e.TextDisplay.CompleteText()
+	} else {
+		e.TextDisplay.ClearText()
+	}
+	se.waitingForInput = data.WaitingForInput
+	se.lineRead = true // 存档时的当前行已经显示过
+
+	se.clearChoices()
+	if data.WaitingForChoice {
+		se.choicesToShow = append([]script.Choice(nil), data.Choices...)
+		se.showChoices()
+	}
+
+	e.state = "game"
+	return nil
+}
+
+// SaveGame 把当前游戏状态写入存档位
+func (e *Engine) SaveGame(slot int) error {
+	if e.state != "game" {
+		return fmt.Errorf("no game in progress")
+	}
+	if err := e.Saves.Save(slot, e.captureState()); err != nil {
+		return err
+	}
+	if err := e.Reads.Save(); err != nil {
+		log.Printf("Failed to save read lines: %v", err)
+	}
+	log.Printf("Saved game to slot %d", slot)
+	return nil
+}
+
+// LoadGame 从存档位恢复游戏状态
+func (e *Engine) LoadGame(slot int) error {
+	data, err := e.Saves.Load(slot)
+	if err != nil {
+		return err
+	}
+	if err := e.restoreState(data); err != nil {
+		return fmt.Errorf("failed to restore save slot %d: %v", slot, err)
+	}
+	// 读档后只能回退到读档位置为止
+	se := e.ScriptEngine
+	se.ClearHistory()
+	if se.waitingForInput || se.waitingForChoice {
+		se.checkpoint()
+	}
+	log.Printf("Loaded game from slot %d", slot)
+	return nil
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"RenGO/script"
+	"reflect"
+	"testing"
+)
+
+// newSaveRunner 返回停在第二个选择支的运行器，存档写到临时目录
+func newSaveRunner(t *testing.T, dir string) *HeadlessRunner {
+	t.Helper()
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Engine.Saves = NewSaveManager(dir)
+	return r
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	saved := newSaveRunner(t, dir)
+	if err := saved.Play([]int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.WaitingForChoice() {
+		t.Fatalf("not waiting for the second choice at %s", saved.Position())
+	}
+	if err := saved.Engine.SaveGame(1); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newSaveRunner(t, dir)
+	if err := loaded.Engine.LoadGame(1); err != nil {
+		t.Fatal(err)
+	}
+	want, got := saved.Engine.captureState(), loaded.Engine.captureState()
+	got.SavedAt = want.SavedAt
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("state after loading:\n%+v\nwant:\n%+v", got, want)
+	}
+	if got, want := loaded.Choices(), saved.Choices(); !reflect.DeepEqual(got, want) {
+		t.Errorf("choices after loading %q, want %q", got, want)
+	}
+
+	// 读档后继续游玩与存档前的游戏走到同一个结局
+	for _, r := range []*HeadlessRunner{saved, loaded} {
+		if err := r.Play([]int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := loaded.Text(), saved.Text(); got != want || got != "Good ending." {
+		t.Errorf("loaded game ends with %q, saved game with %q", got, want)
+	}
+	if got := loaded.Affection("alice"); got != 15 {
+		t.Errorf("affection alice = %d after loading, want 15", got)
+	}
+}
+
+func TestRestoreStateIsAtomic(t *testing.T) {
+	r := newSaveRunner(t, t.TempDir())
+	if err := r.Play([]int{0}); err != nil {
+		t.Fatal(err)
+	}
+	before := r.Engine.captureState()
+
+	bad := []func(data *SaveData){
+		func(data *SaveData) { data.Line = 1 << 20 },
+		func(data *SaveData) { data.Line = -1 },
+		func(data *SaveData) { data.Script = "missing.rgo" },
+		func(data *SaveData) { data.CallStack = []ReturnAddress{{Script: "missing.rgo"}} },
+		func(data *SaveData) {
+			data.Layers[1].Characters = append(data.Layers[1].Characters, CharacterState{Name: "nobody", Parts: map[string]string{}})
+		},
+	}
+	for i, corrupt := range bad {
+		data := r.Engine.captureState()
+		data.Variables = map[string]script.Value{"met": script.IntVal(42)}
+		data.Layers[0].ImagePath = "images/bg/other.png"
+		corrupt(data)
+		if err := r.Engine.restoreState(data); err == nil {
+			t.Errorf("case %d: restoreState accepted a broken save", i)
+			continue
+		}
+		after := r.Engine.captureState()
+		after.SavedAt = before.SavedAt
+		if !reflect.DeepEqual(after, before) {
+			t.Errorf("case %d: failed restore changed the state:\n%+v\nwant:\n%+v", i, after, before)
+		}
+	}
+}
+
+func TestLayerFadeoutKeepsCharacters(t *testing.T) {
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Play(nil); err != nil {
+		t.Fatal(err)
+	}
+	e := r.Engine
+	if err := e.showLayerImage(e.Layers[1], "room", "images/bg/room.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 淡出中途存档：图片按已移除保存，同一图层上的立绘照常保存
+	e.Layers[1].ImageDisplay.Alpha = 0
+	state := r.Layer(1)
+	if state.ImagePath != "" || len(state.Characters) != 1 || state.Characters[0].Name != "alice" {
+		t.Errorf("layer 1 while fading out = %+v, want alice without the image", state)
+	}
+	e.Layers[1].ImageDisplay.Alpha = 1
+
+	// 淡出结束后只移除图层图片
+	if err := e.Animate("fadeout", "1", nil); err != nil {
+		t.Fatal(err)
+	}
+	e.Tweens.Finish()
+	state = r.Layer(1)
+	if state.ImagePath != "" || len(state.Characters) != 1 || state.Characters[0].Name != "alice" {
+		t.Errorf("layer 1 after fadeout = %+v, want alice without the image", state)
+	}
+	if _, char := e.findCharacter("alice"); char == nil || char.Alpha != 1 {
+		t.Errorf("alice after layer fadeout = %+v, want fully visible", char)
+	}
+}
+
+func TestLegacyChara(t *testing.T) {
+	r, err := newRoutesRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Play(nil); err != nil {
+		t.Fatal(err)
+	}
+	e := r.Engine
+
+	// 旧版 @chara 图层 位置 图片：角色名取图片的文件名
+	e.ScriptEngine.handleCharacterCommand([]string{"2", "left", "images/chara/bob.png"})
+	e.ScriptEngine.handleCharacterCommand([]string{"2", "carol", "images/chara/carol.png"})
+	want := map[string]string{"bob": "left", "carol": "center"}
+	got := make(map[string]string)
+	for _, char := range r.Layer(2).Characters {
+		got[char.Name] = char.Slot
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy @chara shows %v, want %v", got, want)
+	}
+
+	// 版本 1 的存档按同样的规则迁移
+	data := e.captureState()
+	data.Version = 1
+	data.Layers[2] = LayerState{Visible: true, CharName: "left", CharPath: "images/chara/bob.png"}
+	data.Layers[3] = LayerState{Visible: true, CharName: "carol", CharPath: "images/chara/carol.png"}
+	if err := e.restoreState(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []CharacterState{{Name: "bob", Slot: "left"}, {Name: "carol", Slot: "center"}} {
+		chars := r.Layer(2 + i).Characters
+		if len(chars) != 1 || chars[0].Name != want.Name || chars[0].Slot != want.Slot {
+			t.Errorf("layer %d after migrating a version 1 save: %+v, want %s at %s", 2+i, chars, want.Name, want.Slot)
+		}
+	}
+}
@@ -1,119 +1,122 @@
 package engine
 
 import (
-	"bufio"
-	"fmt"
+	"RenGO/script"
 	"log"
-	"os"
 	"strconv"
-	"strings"
 )
 
 type ScriptEngine struct {
 	engine           *Engine
 	waitingForChoice bool
 	waitingForInput  bool
-	variables        map[string]interface{}
+	variables        map[string]script.Value
 	currentText      string
 	textReady        bool
 	textQueue        []string
-	choicesToShow    []Choice
+	choicesToShow    []script.Choice
 	pc               int
-	scriptLines      []string
-	currentLine      int
-	affection        map[string]int // 好感度系统
-	pendingJump      string         // 用于存储待执行的跳转目标
-	conditionalStack []bool         // 用于跟踪条件分支的状态
+	script           *script.Script  // 当前编译好的脚本
+	scriptFile       string          // 当前脚本文件路径
+	currentLine      int             // 下一个要执行的节点下标
+	affection        map[string]int  // 好感度系统
+	pendingJump      string          // 用于存储待执行的跳转目标
+	callStack        []ReturnAddress // @call 的返回地址栈
+	registry         *script.Registry
+	labelHook        func(module, label string) // 经过标签时回调，供无窗口运行器记录路线
+	history          rollbackRing               // 回退点
+	lineRead         bool                       // 当前文本行在显示前是否已经读过
 }
 
-func NewScriptEngine(engine *Engine) *ScriptEngine {
+// ReturnAddress 记录 @call 返回时要回到的脚本和节点
+type ReturnAddress struct {
+	Script string `json:"script"`
+	Line   int    `json:"line"`
+}
+
+// 调用栈深度上限，防止脚本无限递归
+const maxCallDepth = 256
+
+func NewScriptEngine(engine *Engine, registry *script.Registry) *ScriptEngine {
 	se := &ScriptEngine{
 		engine:           engine,
 		waitingForChoice: false,
-		variables:        make(map[string]interface{}),
+		variables:        make(map[string]script.Value),
 		textQueue:        make([]string, 0),
 		waitingForInput:  false,
-		scriptLines:      make([]string, 0),
 		currentLine:      0,
 		affection:        make(map[string]int), // 初始化好感度系统
+		registry:         registry,
 	}
 	return se
 }
 
-// 加载KAG脚本并逐行解析
+// 加载脚本模块并从头开始执行，格式错误在这里一次性报告
 func (se *ScriptEngine) LoadScript(scriptName string) error {
-	file, err := os.Open(scriptName)
+	loaded, err := se.registry.Get(scriptName)
 	if err != nil {
-		return fmt.Errorf("failed to read script file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "--") {
-			continue // 跳过空行和注释
-		}
-		se.scriptLines = append(se.scriptLines, line)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read script file: %v", err)
+		return err
 	}
 
+	se.script = loaded
+	se.scriptFile = loaded.Name
 	se.currentLine = 0
 	return nil
 }
 
-// 逐行执行脚本
+// 逐个节点执行脚本
 func (se *ScriptEngine) ExecuteStep() bool {
-	if se.engine.ChoiceSystem.IsWaitingForChoice() {
-		selected, jumpTo := se.engine.ChoiceSystem.HandleInput()
-		if selected {
-			se.clearChoices() // 清除选项
-			se.jumpToLabel(jumpTo)
-			// 立即执行跳转后的所有命令
-			for se.currentLine < len(se.scriptLines) {
-				if !se.ExecuteStep() {
-					break
-				}
-			}
-			return true
-		}
-	}
 	if se.waitingForInput {
 		// 等待用户输入
 		return false
 	}
+	if se.engine.Tweens.Blocking() || se.engine.Transitions.Blocking() {
+		// 等待带 wait 的动画和转场结束
+		return false
+	}
 	if se.pendingJump != "" {
 		se.jumpToLabel(se.pendingJump)
 		se.pendingJump = "" // 清除待跳转
 		return true
 	}
-	// 检查是否已经执行完所有行
-	if se.currentLine >= len(se.scriptLines) {
+	// 检查是否已经执行完所有节点
+	if se.script == nil || se.currentLine >= len(se.script.Nodes) {
 		return false
 	}
 
-	// 获取当前行
-	line := se.scriptLines[se.currentLine]
+	node := se.script.Nodes[se.currentLine]
+	if (node.Kind == script.NodeText || node.Kind == script.NodeChoice) && se.engine.Transitions.Start() {
+		// 先播放之前 @trans 准备好的转场，结束后再显示
+		return false
+	}
 	se.currentLine++
 
-	// 解析并执行当前行
-	if strings.HasPrefix(line, "@") {
-		se.parseCommand(line)
-	} else if strings.HasPrefix(line, ":") {
-		// 标签，暂时忽略
-	} else {
-		// 文本行
-		se.currentText = line
-		se.engine.TextDisplay.SetText(line) // 设置文字内容
+	switch node.Kind {
+	case script.NodeText:
+		se.currentText = node.Text
+		se.lineRead = se.engine.Reads.IsRead(se.scriptFile, node.Pos.Line)
+		se.engine.Reads.MarkRead(se.scriptFile, node.Pos.Line)
+		voice := se.engine.Audio.onTextLine()
+		se.engine.Backlog.Add(node.Text, voice)
+		se.engine.TextDisplay.SetText(node.Text) // 设置文字内容
 		se.waitingForInput = true
-		return true
-	}
-	if len(se.choicesToShow) > 0 {
-		se.showChoices()
-		return true
+		se.checkpoint()
+	case script.NodeCommand:
+		se.runCommand(node)
+	case script.NodeChoice:
+		se.handleChoiceCommand(node)
+		se.checkpoint()
+	case script.NodeIf:
+		se.handleIfCommand(se.currentLine - 1)
+	case script.NodeElseIf, script.NodeElse:
+		// 顺序执行到下一个分支，说明上一个分支已经执行完，跳出整个 if 块
+		se.currentLine = node.End + 1
+	case script.NodeLabel:
+		if se.labelHook != nil {
+			se.labelHook(se.scriptFile, node.Name)
+		}
+	case script.NodeEndIf:
+		// 块结束无需执行
 	}
 	return true
 }
@@ -131,51 +134,93 @@ func (se *ScriptEngine) showChoices() {
 }
 
 func (se *ScriptEngine) clearChoices() {
-	se.choicesToShow = []Choice{}
+	se.choicesToShow = []script.Choice{}
 	se.engine.ChoiceSystem.SetChoices(se.choicesToShow, se.engine.Width)
 	se.waitingForChoice = false
 	se.engine.ChoiceSystem.IsActive = false
 }
 
-// 跳转到指定标签
-func (se *ScriptEngine) jumpToLabel(label string) {
+// 跳转到指定标签，"file.rgo:label" 形式的目标会切换到对应模块
+func (se *ScriptEngine) jumpToLabel(target string) bool {
 	se.clearChoices() // 清除选项
-	for i, line := range se.scriptLines {
-		if strings.HasPrefix(line, ":") && strings.TrimSpace(line[1:]) == label {
-			se.currentLine = i + 1 // 跳转到标签的下一行
-			return
-		}
+	found, index, err := se.registry.Resolve(se.script, target)
+	if err != nil {
+		log.Printf("未找到标签: %s (%v)", target, err)
+		return false
 	}
-	log.Printf("未找到标签: %s", label)
+	se.script = found
+	se.scriptFile = found.Name
+	se.currentLine = index + 1 // 跳转到标签的下一个节点
+	if se.labelHook != nil {
+		se.labelHook(found.Name, found.Nodes[index].Name)
+	}
+	return true
 }
 
-// 解析命令
-func (se *ScriptEngine) parseCommand(line string) {
-	parts := strings.Fields(line[1:])
-	command := parts[0]
-	args := parts[1:]
+// selectChoice 玩家选定选项后跳转到选项目标
+func (se *ScriptEngine) selectChoice(jumpTo string) {
+	se.jumpToLabel(jumpTo)
+	se.waitingForChoice = false
+	se.engine.ChoiceSystem.IsActive = false
+}
+
+// 执行命令节点
+func (se *ScriptEngine) runCommand(node *script.Node) {
+	args := node.Args
 
-	switch command {
+	switch node.Name {
 	case "bg":
 		se.handleBackgroundCommand(args)
 	case "chara":
 		se.handleCharacterCommand(args)
-	case "choice":
-		se.handleChoiceCommand(args)
+	case "hide":
+		se.handleHideCommand(args)
+	case "shake", "flash", "tint", "zoom", "pan":
+		if err := se.engine.CameraCommand(node.Name, args); err != nil {
+			log.Printf("镜头效果 @%s 失败: %v", node.Name, err)
+		}
+	case "shader":
+		if err := se.engine.ShaderCommand(args); err != nil {
+			log.Printf("着色器失败: %v", err)
+		}
+	case "trans":
+		if err := se.engine.Transition(args); err != nil {
+			log.Printf("转场失败: %v", err)
+		}
+	case "move", "fadein", "fadeout", "scale", "rotate", "bounce":
+		se.handleAnimateCommand(node.Name, args)
 	case "affection":
 		se.handleAffectionCommand(args)
-	case "if", "elseif", "else":
-		se.handleIfCommand(parts)
-	case "endif":
-		if len(se.conditionalStack) > 0 {
-			se.conditionalStack = se.conditionalStack[:len(se.conditionalStack)-1]
-		}
 	case "jump":
 		se.handleJumpCommand(args)
 	case "clear":
 		se.handleClearLayer(args)
+	case "set":
+		se.handleSetCommand(node)
+	case "call":
+		se.handleCallCommand(args)
+	case "return":
+		se.handleReturnCommand()
+	case "bgm":
+		se.handleBGMCommand(args)
+	case "stopbgm":
+		opts, _ := script.ParseOptions(args)
+		se.engine.Audio.StopBGM(optionSeconds(opts, "fade", 0))
+	case "se":
+		se.handleSECommand(args)
+	case "stopse":
+		se.engine.Audio.StopSE()
+	case "voice":
+		se.handleVoiceCommand(args)
+	case "stopvoice":
+		se.engine.Audio.StopVoice()
+	case "preload":
+		// 在后台加载之后要用到的图片和声音，无窗口模式下不解码资源
+		if !se.engine.headless {
+			se.engine.Resources.Preload(args)
+		}
 	default:
-		log.Printf("未知命令: %s", command)
+		log.Printf("未知命令: %s (%s)", node.Name, node.Pos)
 	}
 }
 
@@ -189,47 +234,19 @@ func (se *ScriptEngine) handleClearLayer(args []string) {
 	}
 }
 
-func (se *ScriptEngine) effectCommand(command string, args []string) {
-	switch command {
-	case "transition":
-		layerIndex, _ := strconv.Atoi(args[0])
-		targetImage := args[1]
-		maskImage := args[2]
-		//speed, _ := strconv.ParseFloat(args[3], 64)
-
-		// 获取当前图层图像作为源
-		source := se.engine.Layers[layerIndex].ImageDisplay.current
-
-		// 使用ImageDisplay的LoadImage方法加载目标图像
-		targetDisplay := se.engine.Layers[layerIndex].ImageDisplay
-		if err := targetDisplay.LoadImage(targetImage, targetImage); err != nil {
-			log.Printf("加载目标图像失败: %v", err)
-			return
-		}
-		target := targetDisplay.images[targetImage]
-
-		// 添加渐变效果
-		err := se.engine.EffectSystem.AddTransitionEffect(
-			layerIndex,
-			source,
-			target,
-			maskImage,
-			0.01,
-		)
-		if err != nil {
-			log.Printf("添加渐变效果失败: %v", err)
-		}
-
-		// 设置目标图像为图层的新图像
-		//se.engine.Layers[layerIndex].ImageDisplay.current = target
-	}
-}
 func (se *ScriptEngine) handleBackgroundCommand(args []string) {
 	idx, _ := strconv.Atoi(args[0])
 	imagePath := args[1]
-	if len(args) > 2 {
-		fadeCmd := args[3]
-		se.effectCommand(fadeCmd, args)
+	if len(args) > 3 {
+		// 旧写法 @bg 图层 图片 遮罩 transition，等同于图层上的遮罩转场
+		if args[3] == "transition" {
+			trans := []string{args[0], "mask", legacyMaskDuration, "linear", "mask=" + args[2]}
+			if err := se.engine.Transition(trans); err != nil {
+				log.Printf("转场失败: %v", err)
+			}
+		} else {
+			log.Printf("未知背景效果: %s", args[3])
+		}
 	}
 	err := se.engine.SetLayerImage(idx, "background", imagePath)
 	if err != nil {
@@ -239,44 +256,64 @@ func (se *ScriptEngine) handleBackgroundCommand(args []string) {
 	}
 }
 
-// 处理立绘命令
+// 处理立绘命令：@chara 图层 角色名 图片 [位置]，兼容旧版的 @chara 图层 位置 图片，
+// 或组合立绘 @chara 角色名 部件... [at=位置] [layer=图层]
 func (se *ScriptEngine) handleCharacterCommand(args []string) {
+	if !script.IsImageChara(args) {
+		se.handleCharacterPartsCommand(args)
+		return
+	}
 	idx, _ := strconv.Atoi(args[0])
-	position := args[1]
+	name := args[1]
 	imagePath := args[2]
-	err := se.engine.SetLayerCharacter(idx, position, imagePath)
+	slot := ""
+	if len(args) > 3 {
+		slot = args[3]
+	} else {
+		name, slot = se.engine.legacyCharacter(name, imagePath)
+	}
+	err := se.engine.ShowCharacter(idx, name, imagePath, slot)
 	if err != nil {
 		log.Printf("设置立绘失败: %v", err)
 	} else {
-		log.Printf("设置立绘: %s -> %s", position, imagePath)
+		log.Printf("设置立绘: %s -> %s", name, imagePath)
 	}
 }
 
-// 处理选择支命令
-func (se *ScriptEngine) handleChoiceCommand(args []string) {
-	se.clearChoices() // 清除旧的选项
-	for i := 0; i < len(args); i += 3 {
-		if i+2 >= len(args) {
-			log.Printf("选项格式错误: %v", args)
-			break
-		}
-		text := args[i]
-		arrow := args[i+1]
-		jumpTo := args[i+2]
+func (se *ScriptEngine) handleCharacterPartsCommand(args []string) {
+	plain, options := script.SplitOptions(args)
+	opts, _ := script.ParseOptions(options)
+	layer := -1
+	if v, ok := opts["layer"]; ok {
+		layer, _ = strconv.Atoi(v)
+	}
+	name, parts := plain[0], plain[1:]
+	if err := se.engine.ShowCharacterParts(layer, name, opts["at"], parts); err != nil {
+		log.Printf("设置立绘失败: %v", err)
+	} else {
+		log.Printf("设置立绘: %s %v", name, parts)
+	}
+}
 
-		if arrow != "->" {
-			log.Printf("选项格式错误: 缺少 ->")
-			break
-		}
+// 处理隐藏立绘命令：@hide 角色名
+func (se *ScriptEngine) handleHideCommand(args []string) {
+	if err := se.engine.HideCharacter(args[0]); err != nil {
+		log.Printf("隐藏立绘失败: %v", err)
+	}
+}
 
-		se.choicesToShow = append(se.choicesToShow, Choice{
-			Text:   text,
-			JumpTo: jumpTo,
-		})
+// 处理动画命令：@move 目标 位置 [时长] [缓动] [wait] 等，目标是角色名或图层编号
+func (se *ScriptEngine) handleAnimateCommand(command string, args []string) {
+	if err := se.engine.Animate(command, args[0], args[1:]); err != nil {
+		log.Printf("动画 @%s 失败: %v", command, err)
 	}
-	se.engine.ChoiceSystem.SetChoices(se.choicesToShow, se.engine.Width)
-	se.waitingForChoice = true
-	se.engine.ChoiceSystem.IsActive = true
+}
+
+// 处理选择支命令，选项已在编译时解析
+func (se *ScriptEngine) handleChoiceCommand(node *script.Node) {
+	se.clearChoices() // 清除旧的选项
+	se.choicesToShow = append([]script.Choice(nil), node.Choices...)
+	se.showChoices()
 	log.Printf("设置选择支: %v", se.choicesToShow)
 }
 
@@ -289,80 +326,62 @@ func (se *ScriptEngine) handleAffectionCommand(args []string) {
 	log.Printf("好感度变化: %s +%d", character, delta)
 }
 
-func (se *ScriptEngine) handleIfCommand(args []string) {
-	if args[0] == "else" {
-		// 处理 @else
-		if len(se.conditionalStack) > 0 && !se.conditionalStack[len(se.conditionalStack)-1] {
-			se.conditionalStack[len(se.conditionalStack)-1] = true
-		} else {
-			se.skipToEndif()
-		}
-		return
-	}
-
-	var conditionMet bool
-	if args[0] == "elseif" {
-		// 处理 @elseif
-		if len(se.conditionalStack) > 0 && !se.conditionalStack[len(se.conditionalStack)-1] {
-			args = args[1:] // 移除 "elseif" 参数
-			conditionMet = se.evaluateCondition(args)
-			se.conditionalStack[len(se.conditionalStack)-1] = conditionMet
-		} else {
-			se.skipToEndif()
+// 从 if 节点开始依次检查各分支，跳到第一个成立分支的下一个节点
+func (se *ScriptEngine) handleIfCommand(index int) {
+	for {
+		node := se.script.Nodes[index]
+		switch node.Kind {
+		case script.NodeIf, script.NodeElseIf:
+			if se.evaluateCondition(node) {
+				se.currentLine = index + 1
+				return
+			}
+		case script.NodeElse, script.NodeEndIf:
+			se.currentLine = index + 1
 			return
 		}
-	} else {
-		// 处理 @if
-		conditionMet = se.evaluateCondition(args)
-		se.conditionalStack = append(se.conditionalStack, conditionMet)
+		index = node.Next
 	}
+}
 
-	if !conditionMet {
-		se.skipToNextBranch()
-	}
+// Variable 实现 ExprEnv
+func (se *ScriptEngine) Variable(name string) (script.Value, bool) {
+	v, ok := se.variables[name]
+	return v, ok
 }
 
-func (se *ScriptEngine) evaluateCondition(args []string) bool {
-	// 解析条件：affection Yuki >= 5
-	character := args[2]
-	operator := args[3]
-	targetValue, _ := strconv.Atoi(args[4])
-
-	currentValue := se.affection[character]
-
-	switch operator {
-	case ">=":
-		return currentValue >= targetValue
-	case "<=":
-		return currentValue <= targetValue
-	case ">":
-		return currentValue > targetValue
-	case "<":
-		return currentValue < targetValue
-	case "==":
-		return currentValue == targetValue
-	}
-	return false
+// Affection 实现 ExprEnv
+func (se *ScriptEngine) Affection(character string) int {
+	return se.affection[character]
 }
 
-func (se *ScriptEngine) skipToNextBranch() {
-	for se.currentLine < len(se.scriptLines) {
-		line := se.scriptLines[se.currentLine]
-		if strings.HasPrefix(line, "@elseif") || strings.HasPrefix(line, "@else") || strings.HasPrefix(line, "@endif") {
-			return
-		}
-		se.currentLine++
+func (se *ScriptEngine) evaluateCondition(node *script.Node) bool {
+	if node.Expr == nil {
+		return false
+	}
+	v, err := node.Expr.Eval(se)
+	if err != nil {
+		log.Printf("条件求值失败: %v (%s)", err, node.Pos)
+		return false
 	}
+	return v.Truthy()
 }
 
-func (se *ScriptEngine) skipToEndif() {
-	for se.currentLine < len(se.scriptLines) {
-		line := se.scriptLines[se.currentLine]
-		if strings.HasPrefix(line, "@endif") {
-			return
-		}
-		se.currentLine++
+// 处理变量赋值命令：@set name = expr，支持 += -= *= /=
+func (se *ScriptEngine) handleSetCommand(node *script.Node) {
+	name, op := node.Args[0], node.Args[1]
+	v, err := node.Expr.Eval(se)
+	if err != nil {
+		log.Printf("变量赋值失败: %v (%s)", err, node.Pos)
+		return
 	}
+	current, _ := se.Variable(name)
+	if v, err = script.Assign(op, current, v); err != nil {
+		log.Printf("变量赋值失败: %v (%s)", err, node.Pos)
+		return
+	}
+	se.variables[name] = v
+	log.Printf("设置变量: %s = %s", name, v)
 }
 
 // 处理跳转命令
@@ -371,3 +390,90 @@ func (se *ScriptEngine) handleJumpCommand(args []string) {
 	se.pendingJump = jumpTo
 	log.Printf("设置跳转到: %s", jumpTo)
 }
+
+// 处理子程序调用命令，记录返回地址后跳转
+func (se *ScriptEngine) handleCallCommand(args []string) {
+	if len(se.callStack) >= maxCallDepth {
+		log.Printf("调用栈溢出: %s", args[0])
+		return
+	}
+	se.callStack = append(se.callStack, ReturnAddress{Script: se.scriptFile, Line: se.currentLine})
+	if !se.jumpToLabel(args[0]) {
+		se.callStack = se.callStack[:len(se.callStack)-1]
+		return
+	}
+	log.Printf("调用: %s", args[0])
+}
+
+// 处理返回命令，回到最近一次 @call 的下一个节点
+func (se *ScriptEngine) handleReturnCommand() {
+	if len(se.callStack) == 0 {
+		log.Printf("调用栈为空，无法返回")
+		return
+	}
+	ret := se.callStack[len(se.callStack)-1]
+	se.callStack = se.callStack[:len(se.callStack)-1]
+
+	if ret.Script != se.scriptFile {
+		caller, err := se.registry.Get(ret.Script)
+		if err != nil {
+			log.Printf("加载脚本失败: %v", err)
+			return
+		}
+		se.script = caller
+		se.scriptFile = ret.Script
+	}
+	se.currentLine = ret.Line
+	log.Printf("返回到: %s:%d", ret.Script, ret.Line)
+}
+
+// optionFloat 读取数值选项，缺省或格式错误时返回 def
+func optionFloat(opts map[string]string, key string, def float64) float64 {
+	if v, ok := opts[key]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// optionSeconds 读取时长选项，缺省或格式错误时返回 def
+func optionSeconds(opts map[string]string, key string, def float64) float64 {
+	if v, ok := opts[key]; ok {
+		if s, err := script.ParseSeconds(v); err == nil {
+			return s
+		}
+	}
+	return def
+}
+
+// 处理 BGM 命令：@bgm path [fade=1s] [volume=0.8] [loopstart=12.5s] [loopend=80s]
+func (se *ScriptEngine) handleBGMCommand(args []string) {
+	opts, _ := script.ParseOptions(args[1:])
+	points := LoopPoints{
+		Start: optionSeconds(opts, "loopstart", 0),
+		End:   optionSeconds(opts, "loopend", 0),
+	}
+	err := se.engine.Audio.PlayBGM(args[0], optionFloat(opts, "volume", 1), optionSeconds(opts, "fade", 0), points)
+	if err != nil {
+		log.Printf("播放BGM失败: %v", err)
+	} else {
+		log.Printf("播放BGM: %s", args[0])
+	}
+}
+
+// 处理音效命令：@se path [volume=1]
+func (se *ScriptEngine) handleSECommand(args []string) {
+	opts, _ := script.ParseOptions(args[1:])
+	if err := se.engine.Audio.PlaySE(args[0], optionFloat(opts, "volume", 1)); err != nil {
+		log.Printf("播放音效失败: %v", err)
+	}
+}
+
+// 处理语音命令：@voice path [volume=1]，语音在下一行文本显示时停止
+func (se *ScriptEngine) handleVoiceCommand(args []string) {
+	opts, _ := script.ParseOptions(args[1:])
+	if err := se.engine.Audio.PlayVoice(args[0], optionFloat(opts, "volume", 1)); err != nil {
+		log.Printf("播放语音失败: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	settingsFileName  = "settings.json"
+	settingsSaveDelay = 1.0 // 最后一次改动后等待的秒数，拖动音量滑条时不会每帧写一次文件
+	minGameSpeed      = 0.25
+	maxGameSpeed      = 4
+)
+
+// Settings 玩家设置，保存在用户配置目录下，所有存档共用
+type Settings struct {
+	MasterVolume float64 `json:"master_volume"`
+	BGMVolume    float64 `json:"bgm_volume"`
+	SEVolume     float64 `json:"se_volume"`
+	VoiceVolume  float64 `json:"voice_volume"`
+
+	RollbackDepth int  `json:"rollback_depth"` // 最多可回退的行数，0 表示禁用回退
+	SkipReadOnly  bool `json:"skip_read_only"` // 快进只跳过读过的文本
+
+	AutoBaseDelay float64 `json:"auto_base_delay"` // 自动播放每行固定等待的秒数
+	AutoCharDelay float64 `json:"auto_char_delay"` // 自动播放每个字追加等待的秒数
+
+	GameSpeed float64 `json:"game_speed"` // 游戏速度倍率，影响文字、特效和动画
+
+	// 按动作名覆盖默认按键，键名同 ebiten.Key（如 "Enter"、"ArrowUp"），
+	// 手柄按钮名为 a、b、x、y、lb、rb、lt、rt、start、back、up、down、left、right 等
+	KeyBindings     map[string][]string `json:"key_bindings,omitempty"`
+	GamepadBindings map[string][]string `json:"gamepad_bindings,omitempty"`
+
+	path  string  // 为空时不写入磁盘
+	dirty bool    // 有还没写入文件的改动
+	idle  float64 // 距最后一次改动经过的秒数
+}
+
+func DefaultSettings() *Settings {
+	return &Settings{
+		MasterVolume: 1,
+		BGMVolume:    1,
+		SEVolume:     1,
+		VoiceVolume:  1,
+
+		RollbackDepth: defaultRollbackDepth,
+		SkipReadOnly:  true,
+
+		AutoBaseDelay: 1,
+		AutoCharDelay: 0.05,
+
+		GameSpeed: 1,
+	}
+}
+
+// userConfigPath 返回 <用户配置目录>/RenGO/name
+func userConfigPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %v", err)
+	}
+	return filepath.Join(dir, "RenGO", name), nil
+}
+
+// LoadSettings 读取设置文件，文件不存在时返回默认设置，之后 Save 会写到同一路径
+func LoadSettings(path string) (*Settings, error) {
+	s := DefaultSettings()
+	s.path = path
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read settings: %v", err)
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return DefaultSettings(), fmt.Errorf("failed to decode settings: %v", err)
+	}
+	s.clamp()
+	return s, nil
+}
+
+// Save 写回设置文件
+func (s *Settings) Save() error {
+	s.dirty = false // 写入失败时不每帧重试
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %v", err)
+	}
+	if err := writeFileAtomic(s.path, raw); err != nil {
+		return fmt.Errorf("failed to write settings: %v", err)
+	}
+	return nil
+}
+
+// MarkDirty 记录设置已改动，改动停止 settingsSaveDelay 秒后由 Update 写入
+func (s *Settings) MarkDirty() {
+	s.dirty = true
+	s.idle = 0
+}
+
+// Update 推进计时，改动停止足够久后写回设置文件，dt 为真实经过的秒数
+func (s *Settings) Update(dt float64) error {
+	if !s.dirty {
+		return nil
+	}
+	s.idle += dt
+	if s.idle < settingsSaveDelay {
+		return nil
+	}
+	return s.Save()
+}
+
+// Flush 立即写入还没保存的改动，离开标题菜单和退出游戏时调用
+func (s *Settings) Flush() error {
+	if !s.dirty {
+		return nil
+	}
+	return s.Save()
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func (s *Settings) clamp() {
+	s.MasterVolume = clamp01(s.MasterVolume)
+	s.BGMVolume = clamp01(s.BGMVolume)
+	s.SEVolume = clamp01(s.SEVolume)
+	s.VoiceVolume = clamp01(s.VoiceVolume)
+	if s.AutoBaseDelay < 0 {
+		s.AutoBaseDelay = 0
+	}
+	if s.AutoCharDelay < 0 {
+		s.AutoCharDelay = 0
+	}
+	if s.GameSpeed < minGameSpeed || s.GameSpeed > maxGameSpeed {
+		s.GameSpeed = 1
+	}
+	if s.RollbackDepth < 0 {
+		s.RollbackDepth = 0
+	}
+}
+
+// volumeField 按名称取音量字段，名称为 master、bgm、se 或 voice
+func (s *Settings) volumeField(name string) *float64 {
+	switch name {
+	case "master":
+		return &s.MasterVolume
+	case "bgm":
+		return &s.BGMVolume
+	case "se":
+		return &s.SEVolume
+	case "voice":
+		return &s.VoiceVolume
+	}
+	return nil
+}
+
+func (s *Settings) Volume(name string) (float64, bool) {
+	field := s.volumeField(name)
+	if field == nil {
+		return 0, false
+	}
+	return *field, true
+}
+
+func (s *Settings) SetVolume(name string, v float64) bool {
+	field := s.volumeField(name)
+	if field == nil {
+		return false
+	}
+	*field = clamp01(v)
+	return true
+}
+
+// ApplySettings 把设置中的音量应用到正在播放的声音上
+func (e *Engine) ApplySettings() {
+	s := e.Settings
+	e.Audio.SetMasterVolume(s.MasterVolume)
+	e.Audio.SetChannelVolume(ChannelBGM, s.BGMVolume)
+	e.Audio.SetChannelVolume(ChannelSE, s.SEVolume)
+	e.Audio.SetChannelVolume(ChannelVoice, s.VoiceVolume)
+	e.Clock.Speed = s.GameSpeed
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsSaveDebounce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), settingsFileName)
+	s, err := LoadSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := func() float64 {
+		t.Helper()
+		loaded, err := LoadSettings(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loaded.BGMVolume
+	}
+
+	// 拖动滑条时连续改动，停止改动足够久后才写入一次
+	for _, v := range []float64{0.9, 0.8, 0.7} {
+		s.SetVolume("bgm", v)
+		s.MarkDirty()
+		if err := s.Update(settingsSaveDelay / 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("settings written while still changing (%v)", err)
+	}
+	if err := s.Update(settingsSaveDelay); err != nil {
+		t.Fatal(err)
+	}
+	if got := saved(); got != 0.7 {
+		t.Errorf("saved bgm volume %v, want 0.7", got)
+	}
+
+	// 离开菜单时立即写入
+	s.SetVolume("bgm", 0.2)
+	s.MarkDirty()
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := saved(); got != 0.2 {
+		t.Errorf("saved bgm volume %v after flushing, want 0.2", got)
+	}
+
+	// 没有改动时不写文件
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(settingsSaveDelay * 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("settings written without changes (%v)", err)
+	}
+}
@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"RenGO/script"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"io/fs"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// uniformSizes 脚本可以设置的着色器参数类型及其分量个数
+var uniformSizes = map[string]int{"float": 1, "vec2": 2, "vec3": 3, "vec4": 4, "mat2": 4, "mat3": 9, "mat4": 16}
+
+// timeUniform 着色器声明了这个 float 参数时，自动设为应用后经过的秒数
+const timeUniform = "Time"
+
+// CustomShader 着色器目录下的一个 .kage 文件
+type CustomShader struct {
+	Name     string
+	Uniforms map[string]int // 顶层声明的参数及其分量个数
+	shader   *ebiten.Shader // 无窗口模式下不编译，为 nil
+}
+
+// ShaderRegistry 启动时读取着色器目录下的所有 .kage 文件，按不含扩展名的文件名登记
+type ShaderRegistry struct {
+	shaders map[string]*CustomShader
+}
+
+// NewShaderRegistry 编译着色器目录下的所有着色器，编译失败的着色器记录日志后跳过
+func NewShaderRegistry(assets *Assets, dir string, compile bool) *ShaderRegistry {
+	r := &ShaderRegistry{shaders: make(map[string]*CustomShader)}
+	sub, err := assets.Sub(dir)
+	if err != nil {
+		log.Printf("No custom shaders: %v", err)
+		return r
+	}
+	files, err := fs.Glob(sub, "*.kage")
+	if err != nil {
+		log.Printf("No custom shaders: %v", err)
+		return r
+	}
+	for _, file := range files {
+		src, err := fs.ReadFile(sub, file)
+		if err != nil {
+			log.Printf("Failed to read shader %s: %v", file, err)
+			continue
+		}
+		s := &CustomShader{Name: strings.TrimSuffix(file, path.Ext(file)), Uniforms: parseUniforms(src)}
+		if compile {
+			if s.shader, err = ebiten.NewShader(src); err != nil {
+				log.Printf("Failed to compile shader %s: %v", file, err)
+				continue
+			}
+		}
+		r.shaders[s.Name] = s
+	}
+	return r
+}
+
+// uniform 按名称查找参数，脚本中参数名的首字母可以小写，例如 strength 对应 Strength
+func (s *CustomShader) uniform(key string) (string, int, bool) {
+	if size, ok := s.Uniforms[key]; ok {
+		return key, size, true
+	}
+	key = strings.ToUpper(key[:1]) + key[1:]
+	size, ok := s.Uniforms[key]
+	return key, size, ok
+}
+
+func (r *ShaderRegistry) Get(name string) (*CustomShader, bool) {
+	s, ok := r.shaders[name]
+	return s, ok
+}
+
+// parseUniforms 找出 Kage 源码中顶层声明的 float、vec 和 mat 参数，
+// 其他类型的参数脚本不能设置
+func parseUniforms(src []byte) map[string]int {
+	uniforms := make(map[string]int)
+	depth := 0
+	group := false
+	for _, line := range strings.Split(string(src), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if depth == 0 {
+			decl := ""
+			switch {
+			case group && line == ")":
+				group = false
+			case group:
+				decl = line
+			case line == "var (":
+				group = true
+			case strings.HasPrefix(line, "var "):
+				decl = strings.TrimPrefix(line, "var ")
+			}
+			if fields := strings.Fields(decl); len(fields) >= 2 && !strings.Contains(decl, "=") {
+				if size, ok := uniformSizes[fields[len(fields)-1]]; ok {
+					for _, name := range strings.Split(strings.Join(fields[:len(fields)-1], ""), ",") {
+						uniforms[name] = size
+					}
+				}
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+	return uniforms
+}
+
+// ShaderEffect 应用在图层或整个画面上的着色器，按应用顺序依次处理
+type ShaderEffect struct {
+	Name     string               `json:"name"`
+	Uniforms map[string][]float64 `json:"uniforms,omitempty"`
+
+	shader *CustomShader
+	time   float64       // 应用后经过的游戏时间
+	buffer *ebiten.Image // 这一步的输出
+}
+
+func (eff *ShaderEffect) dispose() {
+	if eff.buffer != nil {
+		eff.buffer.Deallocate()
+	}
+}
+
+// PostEffects 管理各图层和整个画面上的着色器
+type PostEffects struct {
+	targets       map[string][]*ShaderEffect
+	sources       map[string]*ebiten.Image // 各目标应用着色器之前的画面
+	registry      *ShaderRegistry
+	width, height int
+}
+
+func NewPostEffects(registry *ShaderRegistry, width, height int) *PostEffects {
+	return &PostEffects{
+		targets:  make(map[string][]*ShaderEffect),
+		sources:  make(map[string]*ebiten.Image),
+		registry: registry,
+		width:    width,
+		height:   height,
+	}
+}
+
+func (pe *PostEffects) find(target, name string) *ShaderEffect {
+	for _, eff := range pe.targets[target] {
+		if eff.Name == name {
+			return eff
+		}
+	}
+	return nil
+}
+
+// Apply 在目标上应用着色器并设置参数。已经应用的着色器只更新参数，
+// 新应用的着色器参数从 0 开始；给出时长时参数通过补间动画变化
+func (pe *PostEffects) Apply(target, name string, uniforms map[string][]float64, tweens *TweenSystem, ta script.TweenArgs) error {
+	s, ok := pe.registry.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown shader %s", name)
+	}
+	resolved := make(map[string][]float64, len(uniforms))
+	for key, value := range uniforms {
+		uniform, size, ok := s.uniform(key)
+		if !ok {
+			return fmt.Errorf("shader %s has no float, vec or mat uniform %s", name, key)
+		}
+		if len(value) != size {
+			return fmt.Errorf("shader %s: uniform %s needs %d values, got %d", name, uniform, size, len(value))
+		}
+		resolved[uniform] = value
+	}
+
+	eff := pe.find(target, name)
+	if eff == nil {
+		eff = &ShaderEffect{Name: name, Uniforms: make(map[string][]float64), shader: s}
+		pe.targets[target] = append(pe.targets[target], eff)
+	}
+	var added []*Tween
+	for key, value := range resolved {
+		current, ok := eff.Uniforms[key]
+		if !ok {
+			current = make([]float64, len(value))
+			eff.Uniforms[key] = current
+		}
+		for i := range value {
+			if ta.Duration > 0 {
+				added = append(added, newTween(&current[i], value[i], ta.Duration, ta.Ease, ta.Wait))
+			} else {
+				current[i] = value[i]
+			}
+		}
+	}
+	if len(added) > 0 {
+		tweens.Add(added...)
+	}
+	return nil
+}
+
+// Remove 移除目标上的着色器，name 为空时移除全部
+func (pe *PostEffects) Remove(target, name string) {
+	kept := pe.targets[target][:0]
+	for _, eff := range pe.targets[target] {
+		if name == "" || eff.Name == name {
+			eff.dispose()
+		} else {
+			kept = append(kept, eff)
+		}
+	}
+	if len(kept) == 0 {
+		delete(pe.targets, target)
+		if src, ok := pe.sources[target]; ok {
+			src.Deallocate()
+			delete(pe.sources, target)
+		}
+		return
+	}
+	pe.targets[target] = kept
+}
+
+func (pe *PostEffects) Update(dt float64) {
+	for _, effects := range pe.targets {
+		for _, eff := range effects {
+			eff.time += dt
+		}
+	}
+}
+
+// Clear 移除所有着色器
+func (pe *PostEffects) Clear() {
+	for target := range pe.targets {
+		pe.Remove(target, "")
+	}
+}
+
+// saved 返回需要存档的着色器，没有时返回 nil
+func (pe *PostEffects) saved() map[string][]ShaderEffect {
+	if len(pe.targets) == 0 {
+		return nil
+	}
+	state := make(map[string][]ShaderEffect, len(pe.targets))
+	for target, effects := range pe.targets {
+		for _, eff := range effects {
+			uniforms := make(map[string][]float64, len(eff.Uniforms))
+			for k, v := range eff.Uniforms {
+				uniforms[k] = append([]float64(nil), v...)
+			}
+			state[target] = append(state[target], ShaderEffect{Name: eff.Name, Uniforms: uniforms})
+		}
+	}
+	return state
+}
+
+// Draw 绘制目标，目标上有着色器时先画到离屏图片上再依次经过各个着色器
+func (pe *PostEffects) Draw(dst *ebiten.Image, target string, draw func(dst *ebiten.Image)) {
+	effects := pe.targets[target]
+	if len(effects) == 0 {
+		draw(dst)
+		return
+	}
+	src, ok := pe.sources[target]
+	if !ok {
+		src = ebiten.NewImage(pe.width, pe.height)
+		pe.sources[target] = src
+	} else {
+		src.Clear()
+	}
+	draw(src)
+
+	for _, eff := range effects {
+		if eff.buffer == nil {
+			eff.buffer = ebiten.NewImage(pe.width, pe.height)
+		} else {
+			eff.buffer.Clear()
+		}
+		op := &ebiten.DrawRectShaderOptions{}
+		op.Images[0] = src
+		op.Uniforms = make(map[string]any, len(eff.Uniforms)+1)
+		for k, v := range eff.Uniforms {
+			op.Uniforms[k] = v
+		}
+		if eff.shader.Uniforms[timeUniform] == 1 {
+			op.Uniforms[timeUniform] = float32(eff.time)
+		}
+		eff.buffer.DrawRectShader(pe.width, pe.height, eff.shader.shader, op)
+		src = eff.buffer
+	}
+	dst.DrawImage(src, nil)
+}
+
+// ShaderCommand 执行 @shader 命令
+func (e *Engine) ShaderCommand(args []string) error {
+	cmd, err := script.ParseShaderCommand(args)
+	if err != nil {
+		return err
+	}
+	if cmd.Target != script.ScreenTarget {
+		if idx, _ := strconv.Atoi(cmd.Target); idx < 0 || idx >= len(e.Layers) {
+			return fmt.Errorf("layer index out of range")
+		}
+	}
+	switch {
+	case cmd.Name == "none":
+		e.PostEffects.Remove(cmd.Target, "")
+	case cmd.Off:
+		e.PostEffects.Remove(cmd.Target, cmd.Name)
+	default:
+		return e.PostEffects.Apply(cmd.Target, cmd.Name, cmd.Uniforms, e.Tweens, cmd.TweenArgs)
+	}
+	return nil
+}
+
+// restoreShaders 按存档重新应用着色器，找不到的着色器记录日志后跳过
+func (e *Engine) restoreShaders(state map[string][]ShaderEffect) {
+	e.PostEffects.Clear()
+	for target, effects := range state {
+		for _, eff := range effects {
+			if err := e.PostEffects.Apply(target, eff.Name, eff.Uniforms, e.Tweens, script.TweenArgs{}); err != nil {
+				log.Printf("Failed to restore shader: %v", err)
+			}
+		}
+	}
+}
@@ -18,8 +18,8 @@ type TextDisplay struct {
 	Color           color.Color
 	MaxWidth        int
 	CharIndex       int
-	CharDelay       int
-	FrameCount      int
+	CharDelay       float64 // 每个字之间的秒数
+	elapsed         float64 // 距上一个字显示经过的秒数
 	WaitingForInput bool
 }
 
@@ -29,7 +29,7 @@ func NewTextDisplay(x, y float64) *TextDisplay {
 		MaxWidth:  600,
 		X:         x,
 		Y:         y,
-		CharDelay: 2, // 每个字符之间的帧数延迟
+		CharDelay: 1.0 / 30,
 	}
 }
 
@@ -43,16 +43,17 @@ func (td *TextDisplay) SetText(s string) {
 	td.CurrentText = s
 	td.IsReady = false
 	td.CharIndex = 0
-	td.FrameCount = 0
+	td.elapsed = 0
 	td.WaitingForInput = false
 }
 
-func (td *TextDisplay) Update() {
-	if !td.IsReady && td.CharIndex < utf8.RuneCountInString(td.CurrentText) {
-		td.FrameCount++
-		if td.FrameCount >= td.CharDelay {
+// Update 推进 dt 秒，一帧可能显示多个字
+func (td *TextDisplay) Update(dt float64) {
+	if n := utf8.RuneCountInString(td.CurrentText); !td.IsReady && td.CharIndex < n {
+		td.elapsed += dt
+		for td.elapsed >= td.CharDelay && td.CharIndex < n {
 			td.CharIndex++
-			td.FrameCount = 0
+			td.elapsed -= td.CharDelay
 		}
 	} else if td.CharIndex >= utf8.RuneCountInString(td.CurrentText) {
 		td.IsReady = true
@@ -121,6 +122,11 @@ func (td *TextDisplay) Draw(screen *ebiten.Image) {
 }
 
 func (td *TextDisplay) wrapText(s string) []string {
+	return wrapLines(td.Font, s, td.MaxWidth)
+}
+
+// wrapLines 按空格把文本折成不超过 maxWidth 的多行，开头以冒号结尾的人名单独占一行
+func wrapLines(face font.Face, s string, maxWidth int) []string {
 	var lines []string
 	words := strings.Split(s, " ")
 	if len(words) == 0 {
@@ -135,7 +141,7 @@ func (td *TextDisplay) wrapText(s string) []string {
 			line = ""
 			continue
 		}
-		if text.BoundString(td.Font, line+" "+word).Dx() <= td.MaxWidth {
+		if text.BoundString(face, line+" "+word).Dx() <= maxWidth {
 			if line == "" {
 				line = word
 			} else {
@@ -175,6 +181,6 @@ func (td *TextDisplay) ClearText() {
 	td.CurrentText = ""
 	td.IsReady = false
 	td.CharIndex = 0
-	td.FrameCount = 0
+	td.elapsed = 0
 	td.WaitingForInput = false
 }
@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"RenGO/script"
+	"embed"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed shaders/*.kage
+var builtinShaders embed.FS
+
+const legacyMaskDuration = "1.5s" // 旧版 @bg 遮罩渐变的时长
+
+// Transition 一次转场。添加时截取目标当前的画面，之后的命令照常修改目标，
+// 脚本执行到下一行文本或选择支时开始播放，每帧把截图与目标现在的画面混合
+type Transition struct {
+	target   string
+	layer    int // 目标是立绘时所在的图层，添加时不在画面上为 -1
+	shader   *ebiten.Shader
+	uniforms map[string]any
+	from     *ebiten.Image
+	to       *ebiten.Image
+	mask     *ebiten.Image
+	ease     script.Easing
+	duration float64
+	elapsed  float64
+	started  bool
+	drawn    bool // 本帧是否已经绘制
+}
+
+func (t *Transition) progress() float64 {
+	if t.duration <= 0 {
+		return 1
+	}
+	return t.ease(min(1, t.elapsed/t.duration))
+}
+
+func (t *Transition) dispose() {
+	t.from.Deallocate()
+	t.to.Deallocate()
+	if t.mask != nil {
+		t.mask.Deallocate()
+	}
+}
+
+// TransitionSystem 管理转场，每个目标同时只有一个转场
+type TransitionSystem struct {
+	transitions   map[string]*Transition
+	shaders       map[string]*ebiten.Shader // 无窗口模式下为 nil，转场不做任何事
+	res           *ResourceManager
+	width, height int
+}
+
+// NewTransitionSystem 编译内置的转场着色器
+func NewTransitionSystem(res *ResourceManager, width, height int, headless bool) *TransitionSystem {
+	ts := &TransitionSystem{
+		transitions: make(map[string]*Transition),
+		res:         res,
+		width:       width,
+		height:      height,
+	}
+	if headless {
+		return ts
+	}
+	ts.shaders = make(map[string]*ebiten.Shader)
+	files, err := builtinShaders.ReadDir("shaders")
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		src, err := builtinShaders.ReadFile("shaders/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		shader, err := ebiten.NewShader(src)
+		if err != nil {
+			panic(fmt.Errorf("failed to compile %s: %v", f.Name(), err))
+		}
+		ts.shaders[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = shader
+	}
+	return ts
+}
+
+// Add 截取目标当前的画面并登记转场，替换目标上还没结束的转场
+func (ts *TransitionSystem) Add(spec *script.TransitionSpec, layer int, draw func(dst *ebiten.Image)) error {
+	if ts.shaders == nil {
+		return nil
+	}
+	t := &Transition{
+		target:   spec.Target,
+		layer:    layer,
+		shader:   ts.shaders[spec.Shader],
+		uniforms: spec.Uniforms,
+		from:     ebiten.NewImage(ts.width, ts.height),
+		to:       ebiten.NewImage(ts.width, ts.height),
+		ease:     spec.Ease,
+		duration: spec.Duration,
+	}
+	if spec.Mask != "" {
+		mask, err := ts.res.Image(spec.Mask)
+		if err != nil {
+			t.dispose()
+			return fmt.Errorf("failed to load mask image: %v", err)
+		}
+		// 着色器要求所有图片大小相同，遮罩拉伸到画面大小
+		t.mask = ebiten.NewImage(ts.width, ts.height)
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(float64(ts.width)/float64(mask.Bounds().Dx()), float64(ts.height)/float64(mask.Bounds().Dy()))
+		op.Filter = ebiten.FilterLinear
+		t.mask.DrawImage(mask, op)
+		ts.res.ReleaseImage(spec.Mask)
+	}
+	draw(t.from)
+
+	if old, ok := ts.transitions[spec.Target]; ok {
+		old.dispose()
+	}
+	ts.transitions[spec.Target] = t
+	return nil
+}
+
+// Start 开始播放所有等待中的转场，有转场开始时返回 true
+func (ts *TransitionSystem) Start() bool {
+	started := false
+	for _, t := range ts.transitions {
+		if !t.started {
+			t.started = true
+			started = true
+		}
+	}
+	return started
+}
+
+// Blocking 是否有正在播放的转场，脚本等转场结束再继续
+func (ts *TransitionSystem) Blocking() bool {
+	for _, t := range ts.transitions {
+		if t.started {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts *TransitionSystem) Update(dt float64) {
+	for target, t := range ts.transitions {
+		if !t.started {
+			continue
+		}
+		t.elapsed += dt
+		if t.elapsed >= t.duration {
+			t.dispose()
+			delete(ts.transitions, target)
+		}
+	}
+}
+
+// Finish 立即结束所有转场，包括还没开始播放的
+func (ts *TransitionSystem) Finish() {
+	ts.Clear()
+}
+
+// Clear 移除所有转场，用于读档和回退
+func (ts *TransitionSystem) Clear() {
+	for _, t := range ts.transitions {
+		t.dispose()
+	}
+	clear(ts.transitions)
+}
+
+func (ts *TransitionSystem) beginFrame() {
+	for _, t := range ts.transitions {
+		t.drawn = false
+	}
+}
+
+// Draw 绘制目标，目标上有转场时把 draw 画出的当前画面与截图混合
+func (ts *TransitionSystem) Draw(dst *ebiten.Image, target string, draw func(dst *ebiten.Image)) {
+	t, ok := ts.transitions[target]
+	if !ok {
+		draw(dst)
+		return
+	}
+	t.drawn = true
+	t.to.Clear()
+	draw(t.to)
+
+	op := &ebiten.DrawRectShaderOptions{}
+	op.Images[0] = t.from
+	op.Images[1] = t.to
+	op.Images[2] = t.mask
+	op.Uniforms = make(map[string]any, len(t.uniforms)+1)
+	for k, v := range t.uniforms {
+		op.Uniforms[k] = v
+	}
+	if !t.started {
+		// 还没开始播放时保持原来的画面
+		op.Uniforms["Progress"] = float32(0)
+	} else {
+		op.Uniforms["Progress"] = float32(t.progress())
+	}
+	dst.DrawRectShader(ts.width, ts.height, t.shader, op)
+}
+
+// drawHidden 绘制转场开始后已经从图层上移除的立绘，让它们也能淡出
+func (ts *TransitionSystem) drawHidden(dst *ebiten.Image, layer int) {
+	for target, t := range ts.transitions {
+		if t.layer == layer && !t.drawn {
+			ts.Draw(dst, target, func(*ebiten.Image) {})
+		}
+	}
+}
+
+// Transition 执行 @trans 命令
+func (e *Engine) Transition(args []string) error {
+	spec, err := script.ParseTransition(args)
+	if err != nil {
+		return err
+	}
+	layer := -1
+	var draw func(dst *ebiten.Image)
+	if spec.Target == script.ScreenTarget {
+		draw = e.drawScene
+	} else if idx, err := strconv.Atoi(spec.Target); err == nil {
+		if idx < 0 || idx >= len(e.Layers) {
+			return fmt.Errorf("layer index out of range")
+		}
+		draw = func(dst *ebiten.Image) { e.drawLayer(dst, idx) }
+	} else if l, char := e.findCharacter(spec.Target); char != nil {
+		layer = e.layerIndex(l)
+		draw = char.Draw
+	} else {
+		// 角色还没登场，转场后出现
+		draw = func(*ebiten.Image) {}
+	}
+	return e.Transitions.Add(spec, layer, draw)
+}
+
+// drawScene 按顺序绘制所有可见图层，再经过整个画面的着色器
+func (e *Engine) drawScene(dst *ebiten.Image) {
+	e.PostEffects.Draw(dst, script.ScreenTarget, func(dst *ebiten.Image) {
+		for i, layer := range e.Layers {
+			if layer.Visible {
+				e.Transitions.Draw(dst, strconv.Itoa(i), func(dst *ebiten.Image) { e.drawLayer(dst, i) })
+			}
+		}
+	})
+}
+
+// drawLayer 绘制图层的图片和立绘，再经过图层上的着色器
+func (e *Engine) drawLayer(dst *ebiten.Image, i int) {
+	e.PostEffects.Draw(dst, strconv.Itoa(i), func(dst *ebiten.Image) { e.drawLayerContent(dst, i) })
+}
+
+// drawLayerContent 绘制图层的图片和立绘，立绘上的转场在这里合成
+func (e *Engine) drawLayerContent(dst *ebiten.Image, i int) {
+	layer := e.Layers[i]
+	layer.ImageDisplay.Draw(dst)
+	for _, char := range layer.CharDisplay.Characters() {
+		e.Transitions.Draw(dst, char.Name, char.Draw)
+	}
+	e.Transitions.drawHidden(dst, i)
+}
@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"RenGO/config"
+	"RenGO/script"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"math"
+	"strconv"
+)
+
+const (
+	defaultFadeDuration = 0.5  // @fadein/@fadeout 不写时长时的秒数
+	bounceDuration      = 0.4  // @bounce 不写时长时的秒数
+	bounceHeight        = 30.0 // @bounce 跳起的像素
+)
+
+// Transform 立绘和图层图片可以做动画的显示属性
+type Transform struct {
+	OffsetX  float64 `json:"offset_x"` // 相对原位置的偏移
+	OffsetY  float64 `json:"offset_y"`
+	Alpha    float64 `json:"alpha"`
+	Scale    float64 `json:"scale"`
+	Rotation float64 `json:"rotation"` // 角度，顺时针
+}
+
+func identityTransform() Transform {
+	return Transform{Alpha: 1, Scale: 1}
+}
+
+// saved 与默认值相同时返回 nil，存档中省略
+func (t Transform) saved() *Transform {
+	if t == identityTransform() {
+		return nil
+	}
+	return &t
+}
+
+// apply 以图片上的 (ax, ay) 为中心缩放、旋转，再把这一点放到画面上的 (x, y)
+func (t *Transform) apply(op *ebiten.DrawImageOptions, ax, ay, x, y float64) {
+	op.GeoM.Translate(-ax, -ay)
+	op.GeoM.Scale(t.Scale, t.Scale)
+	op.GeoM.Rotate(t.Rotation * math.Pi / 180)
+	op.GeoM.Translate(x+t.OffsetX, y+t.OffsetY)
+	op.ColorScale.ScaleAlpha(float32(t.Alpha))
+}
+
+// Tween 在一段时间内把一个属性从当前值变到目标值，起始值在动画开始时读取。
+// 属性每次推进时通过 target 重新查找，目标已经不存在时 target 返回 nil
+type Tween struct {
+	target   func() *float64
+	to       float64
+	from     float64
+	delay    float64 // 开始前等待的秒数，用于串联多个动画
+	duration float64
+	elapsed  float64
+	ease     script.Easing
+	started  bool
+	wait     bool   // 脚本等待动画结束才继续
+	done     func() // 动画结束后调用
+}
+
+// newTween 创建固定属性上的动画，用于镜头、着色器参数等不会被替换的对象
+func newTween(value *float64, to, duration float64, ease script.Easing, wait bool) *Tween {
+	return newTargetTween(func() *float64 { return value }, to, duration, ease, wait)
+}
+
+func newTargetTween(target func() *float64, to, duration float64, ease script.Easing, wait bool) *Tween {
+	return &Tween{target: target, to: to, duration: duration, ease: ease, wait: wait}
+}
+
+// Update 推进 dt 秒，动画结束或目标已经不存在时返回 true
+func (t *Tween) Update(dt float64) bool {
+	value := t.target()
+	if value == nil {
+		return true
+	}
+	t.elapsed += dt
+	if t.elapsed < t.delay {
+		return false
+	}
+	if !t.started {
+		t.from = *value
+		t.started = true
+	}
+	p := 1.0
+	if t.duration > 0 {
+		p = math.Min(1, (t.elapsed-t.delay)/t.duration)
+	}
+	*value = t.from + (t.to-t.from)*t.ease(p)
+	return p >= 1
+}
+
+// TweenSystem 管理所有进行中的补间动画
+type TweenSystem struct {
+	tweens  []*Tween
+	instant bool // 无窗口模式下动画立即完成
+}
+
+func NewTweenSystem(instant bool) *TweenSystem {
+	return &TweenSystem{instant: instant}
+}
+
+// Add 添加一组动画，同一属性上进行中的旧动画被取消
+func (ts *TweenSystem) Add(tweens ...*Tween) {
+	targets := make(map[*float64]bool, len(tweens))
+	for _, t := range tweens {
+		targets[t.target()] = true
+	}
+	kept := ts.tweens[:0]
+	for _, t := range ts.tweens {
+		if value := t.target(); value != nil && !targets[value] {
+			kept = append(kept, t)
+		}
+	}
+	ts.tweens = append(kept, tweens...)
+	if ts.instant {
+		ts.Finish()
+	}
+}
+
+func (ts *TweenSystem) Update(dt float64) {
+	var finished []*Tween
+	kept := ts.tweens[:0]
+	for _, t := range ts.tweens {
+		if t.target() == nil {
+			continue // 目标已经移除，动画一起取消
+		}
+		if t.Update(dt) {
+			finished = append(finished, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	ts.tweens = kept
+	for _, t := range finished {
+		if t.done != nil {
+			t.done()
+		}
+	}
+}
+
+// Finish 立即完成所有动画，用于快进和点击跳过
+func (ts *TweenSystem) Finish() {
+	for len(ts.tweens) > 0 {
+		ts.Update(math.Inf(1))
+	}
+}
+
+// Blocking 是否有脚本需要等待的动画
+func (ts *TweenSystem) Blocking() bool {
+	for _, t := range ts.tweens {
+		if t.wait {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear 取消所有动画，属性停在当前值
+func (ts *TweenSystem) Clear() {
+	ts.tweens = nil
+}
+
+// atEnd 临时把动画中的属性设为结束值再调用 fn，存档时记录的是动画完成后的画面
+func (ts *TweenSystem) atEnd(fn func()) {
+	values := make([]*float64, len(ts.tweens))
+	current := make([]float64, len(ts.tweens))
+	for i, t := range ts.tweens {
+		if values[i] = t.target(); values[i] != nil {
+			current[i] = *values[i]
+			*values[i] = t.to
+		}
+	}
+	fn()
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] != nil {
+			*values[i] = current[i]
+		}
+	}
+}
+
+// animTarget 动画目标：立绘按角色名，图层图片按图层编号。
+// 换表情等操作会替换立绘，所以动画的属性每次推进时按名称重新查找
+type animTarget struct {
+	e     *Engine
+	char  string // 目标是立绘时的角色名
+	layer int
+}
+
+func (e *Engine) animTarget(target string) (*animTarget, error) {
+	if idx, err := strconv.Atoi(target); err == nil {
+		if idx < 0 || idx >= len(e.Layers) {
+			return nil, fmt.Errorf("layer index out of range")
+		}
+		return &animTarget{e: e, layer: idx}, nil
+	}
+	if _, char := e.findCharacter(target); char == nil {
+		return nil, fmt.Errorf("character %s is not on screen", target)
+	}
+	return &animTarget{e: e, char: target, layer: -1}, nil
+}
+
+// resolve 返回目标当前的显示属性和移动时改变的坐标（立绘的位置或图层图片的偏移），
+// 立绘已经不在画面上时返回 nil
+func (t *animTarget) resolve() (transform *Transform, x, y *float64) {
+	if t.char == "" {
+		img := t.e.Layers[t.layer].ImageDisplay
+		return &img.Transform, &img.Transform.OffsetX, &img.Transform.OffsetY
+	}
+	_, char := t.e.findCharacter(t.char)
+	if char == nil {
+		return nil, nil, nil
+	}
+	return &char.Transform, &char.X, &char.Y
+}
+
+// property 生成查找目标某个属性的函数，供 newTargetTween 使用
+func (t *animTarget) property(field func(transform *Transform, x, y *float64) *float64) func() *float64 {
+	return func() *float64 {
+		transform, x, y := t.resolve()
+		if transform == nil {
+			return nil
+		}
+		return field(transform, x, y)
+	}
+}
+
+// Animate 执行一个动画命令，args 是目标之后的参数
+func (e *Engine) Animate(command, target string, args []string) error {
+	t, err := e.animTarget(target)
+	if err != nil {
+		return err
+	}
+	// 第一个参数是目标值的命令
+	value := ""
+	switch command {
+	case "move", "scale", "rotate":
+		value, args = args[0], args[1:]
+	}
+	duration := 0.0
+	switch command {
+	case "fadein", "fadeout":
+		duration = defaultFadeDuration
+	case "bounce":
+		duration = bounceDuration
+	}
+	ta, err := script.ParseTweenArgs(args, duration)
+	if err != nil {
+		return err
+	}
+
+	tween := func(field func(transform *Transform, x, y *float64) *float64, to, duration float64, ease script.Easing) *Tween {
+		return newTargetTween(t.property(field), to, duration, ease, ta.Wait)
+	}
+	posX := func(_ *Transform, x, _ *float64) *float64 { return x }
+	posY := func(_ *Transform, _, y *float64) *float64 { return y }
+	alpha := func(tr *Transform, _, _ *float64) *float64 { return &tr.Alpha }
+	offsetY := func(tr *Transform, _, _ *float64) *float64 { return &tr.OffsetY }
+
+	switch command {
+	case "move":
+		var x, y float64
+		if t.char != "" {
+			if x, y, err = e.Config.SlotPosition(value); err != nil {
+				return err
+			}
+			_, char := e.findCharacter(t.char)
+			char.Slot = value
+		} else if x, y, err = config.ParsePoint(value); err != nil {
+			return err
+		}
+		e.Tweens.Add(tween(posX, x, ta.Duration, ta.Ease), tween(posY, y, ta.Duration, ta.Ease))
+	case "fadein":
+		transform, _, _ := t.resolve()
+		transform.Alpha = 0
+		e.Tweens.Add(tween(alpha, 1, ta.Duration, ta.Ease))
+	case "fadeout":
+		tw := tween(alpha, 0, ta.Duration, ta.Ease)
+		tw.done = func() {
+			// 淡出后移除，之后再显示时不透明度恢复正常。
+			// 图层淡出的只是图层图片，图层上的立绘不受影响
+			if t.char != "" {
+				e.HideCharacter(t.char)
+			} else {
+				e.ClearLayerImage(t.layer)
+			}
+		}
+		e.Tweens.Add(tw)
+	case "scale":
+		s, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid scale %q", value)
+		}
+		e.Tweens.Add(tween(func(tr *Transform, _, _ *float64) *float64 { return &tr.Scale }, s, ta.Duration, ta.Ease))
+	case "rotate":
+		deg, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid angle %q", value)
+		}
+		e.Tweens.Add(tween(func(tr *Transform, _, _ *float64) *float64 { return &tr.Rotation }, deg, ta.Duration, ta.Ease))
+	case "bounce":
+		up := tween(offsetY, -bounceHeight, ta.Duration/2, script.Easings["ease-out"])
+		down := tween(offsetY, 0, ta.Duration/2, script.Easings["ease-in"])
+		down.delay = ta.Duration / 2
+		e.Tweens.Add(up, down)
+	default:
+		return fmt.Errorf("unknown animation %s", command)
+	}
+	return nil
+}
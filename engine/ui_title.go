@@ -5,7 +5,6 @@ package engine
 import (
 	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/yuin/gopher-lua"
 	"log"
@@ -21,10 +20,14 @@ type TitleUI struct {
 	buttons     []Button
 	startTime   time.Time
 	onStartGame func()
+	onLoadGame  func()
 	winX, winY  int
 	//titleBarHeight int
 	isHandCursor bool
 	screen       *ebiten.Image // 用于绘制的屏幕
+	focused      int           // 键盘或手柄选中的按钮下标，-1 表示使用鼠标
+	lastMouseX   int
+	lastMouseY   int
 }
 
 type UIElement struct {
@@ -47,7 +50,7 @@ type Button struct {
 	CurrentHoverIndex int
 }
 
-func NewTitleUI(engine *Engine, onStartGame func()) *TitleUI {
+func NewTitleUI(engine *Engine, onStartGame, onLoadGame func()) *TitleUI {
 	ui := &TitleUI{
 		engine:      engine,
 		luaState:    lua.NewState(),
@@ -56,8 +59,10 @@ func NewTitleUI(engine *Engine, onStartGame func()) *TitleUI {
 		buttons:     make([]Button, 0),
 		startTime:   time.Now(),
 		onStartGame: onStartGame,
+		onLoadGame:  onLoadGame,
 		//titleBarHeight: 70,
 		isHandCursor: false,
+		focused:      -1,
 	}
 
 	ui.loadImages()
@@ -76,7 +81,7 @@ func (ui *TitleUI) loadImages() {
 		"exit_normal", "exit_hover",
 	}
 	for _, name := range imageNames {
-		img, _, err := ebitenutil.NewImageFromFile(fmt.Sprintf("./resource/sys/title/%s.png", name))
+		img, err := ui.engine.Resources.Image(fmt.Sprintf("sys/title/%s.png", name))
 		if err != nil {
 			log.Printf("Failed to load image %s: %v", name, err)
 			continue
@@ -100,6 +105,33 @@ func (ui *TitleUI) setupLuaFunctions() {
 	ui.luaState.SetGlobal("setButtonImage", ui.luaState.NewFunction(ui.setButtonImage))
 	ui.luaState.SetGlobal("setButtonAlpha", ui.luaState.NewFunction(ui.setButtonAlpha))
 	ui.luaState.SetGlobal("onStartGame", ui.luaState.NewFunction(ui.luaOnStartGame))
+	ui.luaState.SetGlobal("getVolume", ui.luaState.NewFunction(ui.getVolume))
+	ui.luaState.SetGlobal("setVolume", ui.luaState.NewFunction(ui.setVolume))
+}
+
+// getVolume(name) 返回 master、bgm、se 或 voice 的音量 (0~1)
+func (ui *TitleUI) getVolume(L *lua.LState) int {
+	name := L.CheckString(1)
+	v, ok := ui.engine.Settings.Volume(name)
+	if !ok {
+		L.ArgError(1, "unknown volume "+name)
+		return 0
+	}
+	L.Push(lua.LNumber(v))
+	return 1
+}
+
+// setVolume(name, value) 立即应用音量，设置文件在改动停止后或离开菜单时写入
+func (ui *TitleUI) setVolume(L *lua.LState) int {
+	name := L.CheckString(1)
+	value := float64(L.CheckNumber(2))
+	if !ui.engine.Settings.SetVolume(name, value) {
+		L.ArgError(1, "unknown volume "+name)
+		return 0
+	}
+	ui.engine.ApplySettings()
+	ui.engine.Settings.MarkDirty()
+	return 0
 }
 
 func (ui *TitleUI) luaOnStartGame(L *lua.LState) int {
@@ -211,7 +243,11 @@ func (ui *TitleUI) getImageSize(L *lua.LState) int {
 }
 func (ui *TitleUI) loadImage(L *lua.LState) int {
 	imageName := L.ToString(1)
-	img, _, err := ebitenutil.NewImageFromFile(fmt.Sprintf("./resource/sys/title/%s.png", imageName))
+	if _, ok := ui.images[imageName]; ok {
+		// 已经持有引用，重复加载直接使用缓存
+		return 0
+	}
+	img, err := ui.engine.Resources.Image(fmt.Sprintf("sys/title/%s.png", imageName))
 	if err != nil {
 		log.Printf("Failed to load image %s: %v", imageName, err)
 		return 0
@@ -314,7 +350,11 @@ func (ui *TitleUI) getImageDimensions(L *lua.LState) int {
 }
 
 func (ui *TitleUI) loadLuaScript() {
-	if err := ui.luaState.DoFile("./resource/sys/title.lua"); err != nil {
+	src, err := ui.engine.Assets.ReadFile("sys/title.lua")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ui.luaState.DoString(string(src)); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -387,15 +427,39 @@ func (ui *TitleUI) registerButton(L *lua.LState) int {
 // 在 engine/ui/title.go 文件中
 func (ui *TitleUI) Update() error {
 	mouseX, mouseY := ebiten.CursorPosition()
-
-	dt := 1.0 / 60.0 // 假设 60 FPS，可以根据实际情况调整
+	clicked := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+
+	// 键盘和手柄选择按钮：把选中按钮的中心当作鼠标位置交给 Lua 的 onMouseHover/onMouseClick，
+	// 鼠标移动后恢复鼠标操作
+	input := ui.engine.Input
+	if mouseX != ui.lastMouseX || mouseY != ui.lastMouseY {
+		ui.lastMouseX, ui.lastMouseY = mouseX, mouseY
+		ui.focused = -1
+	}
+	if n := len(ui.buttons); n > 0 {
+		switch {
+		case input.JustPressed(ActionUp):
+			if ui.focused < 0 {
+				ui.focused = n - 1
+			} else {
+				ui.focused = (ui.focused + n - 1) % n
+			}
+		case input.JustPressed(ActionDown):
+			ui.focused = (ui.focused + 1) % n
+		}
+	}
+	if ui.focused >= 0 {
+		b := ui.buttons[ui.focused]
+		mouseX, mouseY = int(b.X+b.Width/2), int(b.Y+b.Height/2)
+		clicked = clicked || input.JustPressed(ActionConfirm)
+	}
 
 	// 调用 Lua 的 update 函数
 	if err := ui.luaState.CallByParam(lua.P{
 		Fn:      ui.luaState.GetGlobal("update"),
 		NRet:    0,
 		Protect: true,
-	}, lua.LNumber(dt)); err != nil {
+	}, lua.LNumber(ui.engine.Clock.Delta())); err != nil {
 		return fmt.Errorf("error calling Lua update function: %v", err)
 	}
 
@@ -434,7 +498,7 @@ func (ui *TitleUI) Update() error {
 		}
 
 		// 处理鼠标点击
-		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if clicked {
 			if err := ui.luaState.CallByParam(lua.P{
 				Fn:      ui.luaState.GetGlobal("onMouseClick"),
 				NRet:    1,
@@ -457,10 +521,23 @@ func (ui *TitleUI) Update() error {
 				ui.isHandCursor = false
 			case "load_game":
 				log.Println("Load game clicked")
-				// 加载游戏逻辑
+				ebiten.SetCursorShape(ebiten.CursorShapeDefault)
+				ui.isHandCursor = false
+				if ui.onLoadGame != nil {
+					ui.onLoadGame()
+				}
 			case "config":
 				log.Println("Config clicked")
-				// 配置逻辑
+				// 配置界面由 title.lua 的 openConfig 实现，未定义时忽略
+				if fn := ui.luaState.GetGlobal("openConfig"); fn != lua.LNil {
+					if err := ui.luaState.CallByParam(lua.P{
+						Fn:      fn,
+						NRet:    0,
+						Protect: true,
+					}); err != nil {
+						return fmt.Errorf("error calling Lua openConfig function: %v", err)
+					}
+				}
 			case "exit":
 				log.Println("Exit clicked")
 				// 退出逻辑
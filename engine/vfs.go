@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"RenGO/config"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Assets 游戏资源的虚拟文件系统，内容来自资源目录或打包文件，
+// 引擎中所有读取资源的地方都通过它读取
+type Assets struct {
+	fsys fs.FS
+	root string // 资源根目录，用于把带根目录前缀的旧路径转换为资源内路径
+}
+
+// NewDirAssets 直接读取资源目录
+func NewDirAssets(root string) *Assets {
+	return &Assets{fsys: os.DirFS(root), root: root}
+}
+
+// NewArchiveAssets 从打包文件读取资源
+func NewArchiveAssets(a *Archive, root string) *Assets {
+	return &Assets{fsys: a, root: root}
+}
+
+// OpenAssets 配置了打包文件且文件存在时从打包文件读取，否则读取资源目录
+func OpenAssets(cfg *config.Game) (*Assets, error) {
+	if cfg.Archive == "" {
+		return NewDirAssets(cfg.ResourceRoot), nil
+	}
+	if _, err := os.Stat(cfg.Archive); errors.Is(err, os.ErrNotExist) {
+		log.Printf("Archive %s not found, reading assets from %s", cfg.Archive, cfg.ResourceRoot)
+		return NewDirAssets(cfg.ResourceRoot), nil
+	}
+	a, err := OpenArchive(cfg.Archive, []byte(ArchiveKey))
+	if err != nil {
+		return nil, err
+	}
+	return NewArchiveAssets(a, cfg.ResourceRoot), nil
+}
+
+// Name 把资源路径转换为文件系统中的路径，兼容带资源根目录前缀的旧路径
+func (a *Assets) Name(path string) string {
+	name := filepath.Clean(path)
+	if rel, err := filepath.Rel(filepath.Clean(a.root), name); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+	return filepath.ToSlash(name)
+}
+
+func (a *Assets) ReadFile(path string) ([]byte, error) {
+	return fs.ReadFile(a.fsys, a.Name(path))
+}
+
+func (a *Assets) Open(path string) (fs.File, error) {
+	return a.fsys.Open(a.Name(path))
+}
+
+// Sub 返回子目录的文件系统，例如脚本目录
+func (a *Assets) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(a.fsys, a.Name(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", dir, err)
+	}
+	return sub, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"RenGO/config"
+	"RenGO/script"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runGraph 实现 rengo graph：把脚本中的标签和跳转导出为 DOT 或 JSON 流程图
+func runGraph(args []string) int {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	configFile := flags.String("config", config.DefaultFile, "game configuration file")
+	dir := flags.String("dir", "", "directory containing .rgo scripts (default from the config file)")
+	format := flags.String("format", "dot", "output format: dot or json")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "dot" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "graph: unknown format %q\n", *format)
+		return 2
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graph: %v\n", err)
+		return 2
+	}
+	if *dir == "" {
+		*dir = cfg.ScriptRoot()
+	}
+
+	registry := script.NewRegistry(*dir)
+	modules := flags.Args()
+	for i, m := range modules {
+		modules[i] = registry.ModuleName(m)
+	}
+	if len(modules) == 0 {
+		if modules, err = registry.Modules(); err != nil {
+			fmt.Fprintf(os.Stderr, "graph: %v\n", err)
+			return 2
+		}
+	}
+
+	g, err := script.BuildRouteGraph(registry, modules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graph: %v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "graph: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "json" {
+		err = g.WriteJSON(w)
+	} else {
+		err = g.WriteDOT(w)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graph: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -1,12 +1,30 @@
 package main
 
 import (
+	"RenGO/config"
 	"RenGO/engine"
 	"log"
+	"os"
 )
 
 func main() {
-	game := engine.NewEngine(1280, 720, 5)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "graph":
+			os.Exit(runGraph(os.Args[2:]))
+		case "pack":
+			os.Exit(runPack(os.Args[2:]))
+		}
+	}
+
+	cfg, err := config.Load(config.DefaultFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	game, err := engine.NewEngine(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if err := game.Run(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"RenGO/config"
+	"RenGO/engine"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runPack 实现 rengo pack：把资源目录打包成一个文件，可选加密
+func runPack(args []string) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	configFile := flags.String("config", config.DefaultFile, "game configuration file")
+	dir := flags.String("dir", "", "directory to pack (default resource_root from the config file)")
+	output := flags.String("o", "", "archive file (default archive from the config file)")
+	cipherName := flags.String("cipher", "none", "encryption: none, xor or aes")
+	key := flags.String("key", engine.ArchiveKey, "encryption key; the game must be built with the same key")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pack: %v\n", err)
+		return 2
+	}
+	if *dir == "" {
+		*dir = cfg.ResourceRoot
+	}
+	if *output == "" {
+		*output = cfg.Archive
+	}
+	if *output == "" {
+		fmt.Fprintln(os.Stderr, "pack: no output file; pass -o or set archive in the config file")
+		return 2
+	}
+	c, err := engine.ParseArchiveCipher(*cipherName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pack: %v\n", err)
+		return 2
+	}
+
+	n, err := engine.WriteArchive(*output, *dir, c, []byte(*key))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pack: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "packed %d file(s) into %s\n", n, *output)
+	return 0
+}
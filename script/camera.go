@@ -0,0 +1,103 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	defaultShakeDuration = 0.5
+	defaultFlashDuration = 0.3
+)
+
+// parseNumbers 从参数开头读取 n 个数字，不足 n 个时返回 nil 和原参数
+func parseNumbers(args []string, n int) ([]float64, []string) {
+	if len(args) < n {
+		return nil, args
+	}
+	values := make([]float64, n)
+	for i := range values {
+		v, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return nil, args
+		}
+		values[i] = v
+	}
+	return values, args[n:]
+}
+
+// CameraCommand 解析后的镜头命令，目标值之后是时长、缓动和 wait
+type CameraCommand struct {
+	Values []float64
+	Color  []float32
+	TweenArgs
+}
+
+// ParseCameraCommand 解析镜头命令的参数：
+//
+//	@shake 幅度 [时长]
+//	@flash [颜色] [时长]
+//	@tint 颜色|none [时长]
+//	@zoom 倍率 [x y] [时长]
+//	@pan x y [时长]
+func ParseCameraCommand(command string, args []string) (*CameraCommand, error) {
+	cmd := &CameraCommand{}
+	var duration float64
+	switch command {
+	case "shake":
+		if cmd.Values, args = parseNumbers(args, 1); cmd.Values == nil || cmd.Values[0] < 0 {
+			return nil, fmt.Errorf("expected a shake amplitude in pixels")
+		}
+		duration = defaultShakeDuration
+	case "flash":
+		cmd.Color = []float32{1, 1, 1, 1}
+		if len(args) > 0 {
+			if c, err := parseColor(args[0]); err == nil {
+				cmd.Color, args = c, args[1:]
+			}
+		}
+		duration = defaultFlashDuration
+	case "tint":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected a color or none")
+		}
+		if args[0] == "none" {
+			cmd.Color = []float32{1, 1, 1, 1}
+		} else {
+			c, err := parseColor(args[0])
+			if err != nil {
+				return nil, err
+			}
+			cmd.Color = c
+		}
+		args = args[1:]
+	case "zoom":
+		if cmd.Values, args = parseNumbers(args, 1); cmd.Values == nil || cmd.Values[0] <= 0 {
+			return nil, fmt.Errorf("expected a positive zoom factor")
+		}
+		// 焦点坐标必须成对给出
+		if point, rest := parseNumbers(args, 2); point != nil {
+			cmd.Values, args = append(cmd.Values, point...), rest
+		}
+	case "pan":
+		if cmd.Values, args = parseNumbers(args, 2); cmd.Values == nil {
+			return nil, fmt.Errorf("expected x and y")
+		}
+	default:
+		return nil, fmt.Errorf("unknown camera command %s", command)
+	}
+	ta, err := ParseTweenArgs(args, duration)
+	if err != nil {
+		return nil, err
+	}
+	cmd.TweenArgs = ta
+	return cmd, nil
+}
+
+// cameraValidator 生成镜头命令的参数检查
+func cameraValidator(command string) func(args []string) error {
+	return func(args []string) error {
+		_, err := ParseCameraCommand(command, args)
+		return err
+	}
+}
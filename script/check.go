@@ -0,0 +1,89 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Lint 对编译好的模块做静态检查：未知命令、不存在的跳转目标和缺失的资源文件。
+// 跨模块的跳转目标通过 registry 查找，资源路径由 resolve 转换为实际文件路径
+func Lint(script *Script, registry *Registry, resolve func(string) string) []*ParseError {
+	var problems []*ParseError
+	report := func(pos Pos, format string, args ...interface{}) {
+		problems = append(problems, &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	}
+	checkLabel := func(pos Pos, target string) {
+		file, label := splitTarget(target)
+		targetScript := script
+		if file != "" {
+			// 被引用的模块有错误时仍用部分结果查找标签，错误在检查该模块时报告
+			other, _ := registry.Get(file)
+			if other == nil {
+				report(pos, "jump target %q refers to missing script %s", target, file)
+				return
+			}
+			targetScript = other
+		}
+		if _, _, ok := targetScript.findLabel(label, make(map[*Script]bool)); !ok {
+			report(pos, "jump target %q is not defined", target)
+		}
+	}
+
+	for _, node := range script.Nodes {
+		switch node.Kind {
+		case NodeChoice:
+			for _, choice := range node.Choices {
+				checkLabel(node.Pos, choice.JumpTo)
+			}
+		case NodeCommand:
+			spec, ok := commandSpecs[node.Name]
+			if !ok {
+				report(node.Pos, "unknown command @%s", node.Name)
+				continue
+			}
+			for _, i := range spec.labelArgs {
+				if i < len(node.Args) {
+					checkLabel(node.Pos, node.Args[i])
+				}
+			}
+			paths := spec.pathArgs
+			if spec.paths != nil && len(node.Args) > 0 {
+				paths = spec.paths(node.Args)
+			}
+			for _, i := range paths {
+				if i < len(node.Args) {
+					// 路径也可能写在 key=value 选项中
+					file := node.Args[i]
+					if _, v, ok := strings.Cut(file, "="); ok {
+						file = v
+					}
+					if _, err := os.Stat(resolve(file)); err != nil {
+						report(node.Pos, "file %q not found", file)
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// Check 按游戏运行时的方式编译模块并运行静态检查，返回所有问题
+func (r *Registry) Check(name string, resolve func(string) string) []error {
+	var problems []error
+	script, err := r.Get(name)
+	if parseErrs, ok := err.(ParseErrors); ok {
+		for _, e := range parseErrs {
+			problems = append(problems, e)
+		}
+	} else if err != nil {
+		problems = append(problems, err)
+	}
+	if script == nil {
+		return problems
+	}
+	for _, e := range Lint(script, r, resolve) {
+		problems = append(problems, e)
+	}
+	return problems
+}
@@ -0,0 +1,677 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ValueKind 脚本变量的类型
+type ValueKind int
+
+const (
+	IntValue ValueKind = iota
+	FloatValue
+	BoolValue
+	StringValue
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case IntValue:
+		return "int"
+	case FloatValue:
+		return "float"
+	case BoolValue:
+		return "bool"
+	case StringValue:
+		return "string"
+	}
+	return "unknown"
+}
+
+// Value 是脚本中的一个值
+type Value struct {
+	Kind  ValueKind
+	Int   int64
+	Float float64
+	Bool  bool
+	Str   string
+}
+
+func IntVal(i int64) Value     { return Value{Kind: IntValue, Int: i} }
+func FloatVal(f float64) Value { return Value{Kind: FloatValue, Float: f} }
+func BoolVal(b bool) Value     { return Value{Kind: BoolValue, Bool: b} }
+func StringVal(s string) Value { return Value{Kind: StringValue, Str: s} }
+
+func (v Value) String() string {
+	switch v.Kind {
+	case IntValue:
+		return strconv.FormatInt(v.Int, 10)
+	case FloatValue:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case BoolValue:
+		return strconv.FormatBool(v.Bool)
+	}
+	return v.Str
+}
+
+// Truthy 用于条件判断：false、0、0.0 和空字符串为假
+func (v Value) Truthy() bool {
+	switch v.Kind {
+	case IntValue:
+		return v.Int != 0
+	case FloatValue:
+		return v.Float != 0
+	case BoolValue:
+		return v.Bool
+	}
+	return v.Str != ""
+}
+
+func (v Value) isNumber() bool {
+	return v.Kind == IntValue || v.Kind == FloatValue
+}
+
+func (v Value) number() float64 {
+	if v.Kind == IntValue {
+		return float64(v.Int)
+	}
+	return v.Float
+}
+
+type jsonValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON 带上类型写入存档，保证读档后 int 和 float 不混淆
+func (v Value) MarshalJSON() ([]byte, error) {
+	var raw []byte
+	var err error
+	switch v.Kind {
+	case IntValue:
+		raw, err = json.Marshal(v.Int)
+	case FloatValue:
+		raw, err = json.Marshal(v.Float)
+	case BoolValue:
+		raw, err = json.Marshal(v.Bool)
+	default:
+		raw, err = json.Marshal(v.Str)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{Type: v.Kind.String(), Value: raw})
+}
+
+// UnmarshalJSON 同时接受带类型的对象和普通 JSON 标量
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var typed jsonValue
+	if err := json.Unmarshal(data, &typed); err == nil && typed.Type != "" {
+		switch typed.Type {
+		case "int":
+			v.Kind = IntValue
+			return json.Unmarshal(typed.Value, &v.Int)
+		case "float":
+			v.Kind = FloatValue
+			return json.Unmarshal(typed.Value, &v.Float)
+		case "bool":
+			v.Kind = BoolValue
+			return json.Unmarshal(typed.Value, &v.Bool)
+		case "string":
+			v.Kind = StringValue
+			return json.Unmarshal(typed.Value, &v.Str)
+		}
+		return fmt.Errorf("unknown value type %q", typed.Type)
+	}
+
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+	switch p := plain.(type) {
+	case float64:
+		if p == math.Trunc(p) && math.Abs(p) < 1<<53 {
+			*v = IntVal(int64(p))
+		} else {
+			*v = FloatVal(p)
+		}
+	case bool:
+		*v = BoolVal(p)
+	case string:
+		*v = StringVal(p)
+	default:
+		return fmt.Errorf("unsupported value %s", data)
+	}
+	return nil
+}
+
+// ExprEnv 提供表达式求值时需要的变量和好感度
+type ExprEnv interface {
+	Variable(name string) (Value, bool)
+	Affection(character string) int
+}
+
+// Expr 是编译好的表达式
+type Expr interface {
+	Eval(env ExprEnv) (Value, error)
+}
+
+type literalExpr struct{ value Value }
+
+type variableExpr struct{ name string }
+
+type affectionExpr struct{ character string }
+
+type unaryExpr struct {
+	op string
+	x  Expr
+}
+
+type binaryExpr struct {
+	op   string
+	l, r Expr
+}
+
+func (e *literalExpr) Eval(env ExprEnv) (Value, error) {
+	return e.value, nil
+}
+
+// 未定义的变量视为 0，这样没设置过的标记自然为假
+func (e *variableExpr) Eval(env ExprEnv) (Value, error) {
+	if v, ok := env.Variable(e.name); ok {
+		return v, nil
+	}
+	return IntVal(0), nil
+}
+
+func (e *affectionExpr) Eval(env ExprEnv) (Value, error) {
+	return IntVal(int64(env.Affection(e.character))), nil
+}
+
+func (e *unaryExpr) Eval(env ExprEnv) (Value, error) {
+	x, err := e.x.Eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	switch e.op {
+	case "not":
+		return BoolVal(!x.Truthy()), nil
+	case "-":
+		switch x.Kind {
+		case IntValue:
+			return IntVal(-x.Int), nil
+		case FloatValue:
+			return FloatVal(-x.Float), nil
+		}
+		return Value{}, fmt.Errorf("cannot negate %s", x.Kind)
+	}
+	return Value{}, fmt.Errorf("unknown operator %q", e.op)
+}
+
+func (e *binaryExpr) Eval(env ExprEnv) (Value, error) {
+	l, err := e.l.Eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	// and/or 短路求值
+	switch e.op {
+	case "and":
+		if !l.Truthy() {
+			return BoolVal(false), nil
+		}
+		r, err := e.r.Eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		return BoolVal(r.Truthy()), nil
+	case "or":
+		if l.Truthy() {
+			return BoolVal(true), nil
+		}
+		r, err := e.r.Eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		return BoolVal(r.Truthy()), nil
+	}
+
+	r, err := e.r.Eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	return applyBinary(e.op, l, r)
+}
+
+// applyBinary 计算算术和比较运算，int 与 float 混合时按 float 计算
+func applyBinary(op string, l, r Value) (Value, error) {
+	switch op {
+	case "==", "!=":
+		eq := false
+		switch {
+		case l.isNumber() && r.isNumber():
+			eq = l.number() == r.number()
+		case l.Kind == r.Kind:
+			eq = l == r
+		}
+		return BoolVal(eq == (op == "==")), nil
+	case "<", "<=", ">", ">=":
+		var cmp int
+		switch {
+		case l.Kind == IntValue && r.Kind == IntValue:
+			if l.Int < r.Int {
+				cmp = -1
+			} else if l.Int > r.Int {
+				cmp = 1
+			}
+		case l.isNumber() && r.isNumber():
+			if a, b := l.number(), r.number(); a < b {
+				cmp = -1
+			} else if a > b {
+				cmp = 1
+			}
+		case l.Kind == StringValue && r.Kind == StringValue:
+			cmp = strings.Compare(l.Str, r.Str)
+		default:
+			return Value{}, fmt.Errorf("cannot compare %s with %s", l.Kind, r.Kind)
+		}
+		switch op {
+		case "<":
+			return BoolVal(cmp < 0), nil
+		case "<=":
+			return BoolVal(cmp <= 0), nil
+		case ">":
+			return BoolVal(cmp > 0), nil
+		}
+		return BoolVal(cmp >= 0), nil
+	case "+":
+		if l.Kind == StringValue && r.Kind == StringValue {
+			return StringVal(l.Str + r.Str), nil
+		}
+	}
+
+	if !l.isNumber() || !r.isNumber() {
+		return Value{}, fmt.Errorf("operator %s not defined for %s and %s", op, l.Kind, r.Kind)
+	}
+	if l.Kind == IntValue && r.Kind == IntValue {
+		a, b := l.Int, r.Int
+		switch op {
+		case "+":
+			return IntVal(a + b), nil
+		case "-":
+			return IntVal(a - b), nil
+		case "*":
+			return IntVal(a * b), nil
+		case "/", "%":
+			if b == 0 {
+				return Value{}, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				return IntVal(a / b), nil
+			}
+			return IntVal(a % b), nil
+		}
+	} else {
+		a, b := l.number(), r.number()
+		switch op {
+		case "+":
+			return FloatVal(a + b), nil
+		case "-":
+			return FloatVal(a - b), nil
+		case "*":
+			return FloatVal(a * b), nil
+		case "/":
+			if b == 0 {
+				return Value{}, fmt.Errorf("division by zero")
+			}
+			return FloatVal(a / b), nil
+		case "%":
+			if b == 0 {
+				return Value{}, fmt.Errorf("division by zero")
+			}
+			return FloatVal(math.Mod(a, b)), nil
+		}
+	}
+	return Value{}, fmt.Errorf("unknown operator %q", op)
+}
+
+// Assign 计算 @set 赋值后的值，op 为 = 或 += -= *= /=。
+// 复合赋值时原值不是数字或字符串（包括未设置过的变量）按 0 计算
+func Assign(op string, current, v Value) (Value, error) {
+	if op == "=" {
+		return v, nil
+	}
+	if !current.isNumber() && current.Kind != StringValue {
+		current = IntVal(0)
+	}
+	return applyBinary(op[:1], current, v)
+}
+
+// exprToken 表达式词法单元
+type exprToken struct {
+	kind string // num, str, ident, op, eof
+	text string
+	col  int
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: "num", text: string(runes[start:i]), col: col})
+		case r == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				i++
+				if c == '"' {
+					closed = true
+					break
+				}
+				if c == '\\' && i < len(runes) {
+					c = runes[i]
+					i++
+					if c == 'n' {
+						c = '\n'
+					}
+				}
+				sb.WriteRune(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at column %d", col)
+			}
+			tokens = append(tokens, exprToken{kind: "str", text: sb.String(), col: col})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: string(runes[start:i]), col: col})
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%()<>!", op) && len(op) == 1 {
+				return nil, fmt.Errorf("unexpected character %q at column %d", r, col)
+			}
+			i += len([]rune(op))
+			tokens = append(tokens, exprToken{kind: "op", text: op, col: col})
+		}
+	}
+	tokens = append(tokens, exprToken{kind: "eof", col: len(runes) + 1})
+	return tokens, nil
+}
+
+// exprParser 递归下降解析，优先级从低到高：or、and、not、比较、加减、乘除、负号
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+// accept 匹配运算符或关键字，并把 &&、||、! 统一为 and、or、not
+func (p *exprParser) accept(words ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" && t.kind != "ident" {
+		return "", false
+	}
+	for _, w := range words {
+		if t.text == w {
+			p.next()
+			switch w {
+			case "&&":
+				return "and", true
+			case "||":
+				return "or", true
+			case "!":
+				return "not", true
+			}
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("or", "||")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("and", "&&")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseNot() (Expr, error) {
+	if op, ok := p.accept("not", "!"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (Expr, error) {
+	l, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.accept("==", "!=", "<=", ">=", "<", ">"); ok {
+		r, err := p.parseAdd()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryExpr{op: op, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseAdd() (Expr, error) {
+	l, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseMul() (Expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseUnary() (Expr, error) {
+	if op, ok := p.accept("-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case "num":
+		if strings.Contains(t.text, ".") {
+			f, err := strconv.ParseFloat(t.text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at column %d", t.text, t.col)
+			}
+			return &literalExpr{FloatVal(f)}, nil
+		}
+		i, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at column %d", t.text, t.col)
+		}
+		return &literalExpr{IntVal(i)}, nil
+	case "str":
+		return &literalExpr{StringVal(t.text)}, nil
+	case "ident":
+		switch t.text {
+		case "true":
+			return &literalExpr{BoolVal(true)}, nil
+		case "false":
+			return &literalExpr{BoolVal(false)}, nil
+		case "affection":
+			// affection Yuki 读取角色好感度
+			if p.peek().kind == "ident" {
+				return &affectionExpr{character: p.next().text}, nil
+			}
+		case "and", "or", "not":
+			return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+		}
+		return &variableExpr{name: t.text}, nil
+	case "op":
+		if t.text == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.next(); closing.text != ")" {
+				return nil, fmt.Errorf("expected \")\" at column %d", closing.col)
+			}
+			return x, nil
+		}
+	case "eof":
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+}
+
+// ParseExpr 编译一个表达式，例如 affection Yuki >= 5 and not met_at_library
+func ParseExpr(src string) (Expr, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+	}
+	return expr, nil
+}
+
+// parseAssignment 解析 @set 的参数：name = expr、name += expr 或单独的 name（设为 true）
+func parseAssignment(raw string) (name, op string, expr Expr, err error) {
+	raw = strings.TrimSpace(raw)
+	end := strings.IndexFunc(raw, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+	})
+	if end < 0 {
+		end = len(raw)
+	}
+	name = raw[:end]
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		return "", "", nil, fmt.Errorf("expected variable name")
+	}
+	switch name {
+	case "affection", "true", "false", "and", "or", "not":
+		return "", "", nil, fmt.Errorf("%q is a reserved word", name)
+	}
+	rest := strings.TrimSpace(raw[end:])
+	if rest == "" {
+		return name, "=", &literalExpr{BoolVal(true)}, nil
+	}
+	for _, candidate := range []string{"+=", "-=", "*=", "/=", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return "", "", nil, fmt.Errorf("expected \"=\" after %s", name)
+	}
+	expr, err = ParseExpr(rest[len(op):])
+	if err != nil {
+		return "", "", nil, err
+	}
+	return name, op, expr, nil
+}
@@ -0,0 +1,233 @@
+package script
+
+import (
+	"strings"
+	"testing"
+)
+
+// testEnv 提供固定的变量和好感度
+type testEnv struct {
+	vars      map[string]Value
+	affection map[string]int
+}
+
+func (e testEnv) Variable(name string) (Value, bool) {
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+func (e testEnv) Affection(character string) int {
+	return e.affection[character]
+}
+
+var exprEnv = testEnv{
+	vars: map[string]Value{
+		"t":    BoolVal(true),
+		"f":    BoolVal(false),
+		"n":    IntVal(3),
+		"x":    FloatVal(1.5),
+		"name": StringVal("yuki"),
+	},
+	affection: map[string]int{"Yuki": 7},
+}
+
+func TestEvalExpr(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		// 优先级
+		{"1 + 2 * 3", IntVal(7)},
+		{"(1 + 2) * 3", IntVal(9)},
+		{"10 - 4 - 3", IntVal(3)},
+		{"7 / 2", IntVal(3)},
+		{"7 % 4", IntVal(3)},
+		{"-n * 2", IntVal(-6)},
+		{"- -n", IntVal(3)},
+		{"-(1 + 2)", IntVal(-3)},
+		{"1 + 2 == 3", BoolVal(true)},
+		{"not f and t", BoolVal(true)},
+		{"not (f and t)", BoolVal(true)},
+		{"not t and f", BoolVal(false)},
+		{"t or t and f", BoolVal(true)},
+		{"(t or t) and f", BoolVal(false)},
+		{"!f && t || f", BoolVal(true)},
+		{"n > 2 and n < 4", BoolVal(true)},
+
+		// 数字与字符串比较
+		{"1 == 1.0", BoolVal(true)},
+		{"n == 3.0", BoolVal(true)},
+		{"x > 1", BoolVal(true)},
+		{"x + 1", FloatVal(2.5)},
+		{"3 / 2.0", FloatVal(1.5)},
+		{`"a" == "a"`, BoolVal(true)},
+		{`"a" != "b"`, BoolVal(true)},
+		{`"a" < "b"`, BoolVal(true)},
+		{`"1" == 1`, BoolVal(false)},
+		{`"1" != 1`, BoolVal(true)},
+		{`t == 1`, BoolVal(false)},
+		{`name == "yuki"`, BoolVal(true)},
+		{`"a" + "b"`, StringVal("ab")},
+		{`"say \"hi\"\n"`, StringVal("say \"hi\"\n")},
+
+		// 好感度与未定义的变量
+		{"affection Yuki >= 5", BoolVal(true)},
+		{"affection Yuki + 1", IntVal(8)},
+		{"affection Kana", IntVal(0)},
+		{"affection Yuki >= 5 and not met", BoolVal(true)},
+		{"unset", IntVal(0)},
+		{"affection", IntVal(0)},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.src)
+		if err != nil {
+			t.Errorf("ParseExpr(%s): %v", tt.src, err)
+			continue
+		}
+		got, err := expr.Eval(exprEnv)
+		if err != nil {
+			t.Errorf("Eval(%s): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%s) = %s %v, want %s %v", tt.src, got.Kind, got, tt.want.Kind, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{`"abc`, "unterminated string at column 1"},
+		{`name == "abc`, "unterminated string at column 9"},
+		{"a = 1", `unexpected character '=' at column 3`},
+		{"a & b", `unexpected character '&' at column 3`},
+		{"1 +", "unexpected end of expression"},
+		{"", "unexpected end of expression"},
+		{"(1", `expected ")" at column 3`},
+		{"(1 + 2", `expected ")" at column 7`},
+		{"1 2", `unexpected "2" at column 3`},
+		{"a == b == c", `unexpected "==" at column 8`},
+		{"1 )", `unexpected ")" at column 3`},
+		{"and", `unexpected "and" at column 1`},
+		{"a or or b", `unexpected "or" at column 6`},
+		{"not", "unexpected end of expression"},
+		{"1.2.3", `invalid number "1.2.3" at column 1`},
+		{"* 2", `unexpected "*" at column 1`},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpr(tt.src)
+		if err == nil {
+			t.Errorf("ParseExpr(%s) succeeded", tt.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("ParseExpr(%s) error %q, want %q", tt.src, err, tt.msg)
+		}
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1.0 % 0", "division by zero"},
+		{`"a" < 1`, "cannot compare string with int"},
+		{`"a" - "b"`, "operator - not defined for string and string"},
+		{`t + 1`, "operator + not defined for bool and int"},
+		{`-name`, "cannot negate string"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.src)
+		if err != nil {
+			t.Errorf("ParseExpr(%s): %v", tt.src, err)
+			continue
+		}
+		if _, err := expr.Eval(exprEnv); err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Eval(%s) error %v, want %q", tt.src, err, tt.msg)
+		}
+	}
+	// and/or 短路时不对右侧求值
+	for _, src := range []string{"f and 1 / 0", "t or 1 / 0"} {
+		expr, err := ParseExpr(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := expr.Eval(exprEnv); err != nil {
+			t.Errorf("Eval(%s): %v", src, err)
+		}
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	tests := []struct {
+		raw     string
+		name    string
+		op      string
+		current Value
+		want    Value
+	}{
+		{"met", "met", "=", IntVal(0), BoolVal(true)},
+		{"route = \"library\"", "route", "=", IntVal(0), StringVal("library")},
+		{"count=n+1", "count", "=", IntVal(0), IntVal(4)},
+		{"count += 2", "count", "+=", IntVal(5), IntVal(7)},
+		{"count -= 2", "count", "-=", IntVal(5), IntVal(3)},
+		{"count *= 1.5", "count", "*=", IntVal(2), FloatVal(3)},
+		{"count /= 2", "count", "/=", IntVal(9), IntVal(4)},
+		{"title += \"!\"", "title", "+=", StringVal("hi"), StringVal("hi!")},
+		// 复合赋值时没设置过或不是数字的原值按 0 计算
+		{"count += 1", "count", "+=", Value{}, IntVal(1)},
+		{"flag += 1", "flag", "+=", BoolVal(true), IntVal(1)},
+		{"好感 = 1", "好感", "=", IntVal(0), IntVal(1)},
+	}
+	for _, tt := range tests {
+		name, op, expr, err := parseAssignment(tt.raw)
+		if err != nil {
+			t.Errorf("parseAssignment(%s): %v", tt.raw, err)
+			continue
+		}
+		if name != tt.name || op != tt.op {
+			t.Errorf("parseAssignment(%s) = %s %s, want %s %s", tt.raw, name, op, tt.name, tt.op)
+			continue
+		}
+		v, err := expr.Eval(exprEnv)
+		if err != nil {
+			t.Errorf("Eval(%s): %v", tt.raw, err)
+			continue
+		}
+		got, err := Assign(op, tt.current, v)
+		if err != nil {
+			t.Errorf("Assign(%s): %v", tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s with %v = %s %v, want %s %v", tt.raw, tt.current, got.Kind, got, tt.want.Kind, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		raw string
+		msg string
+	}{
+		{"", "expected variable name"},
+		{"= 1", "expected variable name"},
+		{"1abc = 2", "expected variable name"},
+		{"affection = 1", `"affection" is a reserved word`},
+		{"not", `"not" is a reserved word`},
+		{"a == 1", "unexpected"},
+		{"a 1", `expected "=" after a`},
+		{"a %= 1", `expected "=" after a`},
+		{"a = ", "unexpected end of expression"},
+		{"a = (1", `expected ")"`},
+	}
+	for _, tt := range invalid {
+		_, _, _, err := parseAssignment(tt.raw)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("parseAssignment(%s) error %v, want %q", tt.raw, err, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,533 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NodeKind 脚本节点类型
+type NodeKind int
+
+const (
+	NodeText NodeKind = iota
+	NodeCommand
+	NodeLabel
+	NodeIf
+	NodeElseIf
+	NodeElse
+	NodeEndIf
+	NodeChoice
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeText:
+		return "text"
+	case NodeCommand:
+		return "command"
+	case NodeLabel:
+		return "label"
+	case NodeIf:
+		return "if"
+	case NodeElseIf:
+		return "elseif"
+	case NodeElse:
+		return "else"
+	case NodeEndIf:
+		return "endif"
+	case NodeChoice:
+		return "choice"
+	}
+	return "unknown"
+}
+
+// Choice 选择支中的一个选项
+type Choice struct {
+	Text   string
+	JumpTo string
+}
+
+// Pos 节点在源文件中的位置
+type Pos struct {
+	File string
+	Line int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// Node 编译后的脚本节点
+type Node struct {
+	Kind    NodeKind
+	Pos     Pos
+	Text    string   // 文本行内容
+	Name    string   // 命令名或标签名
+	Args    []string // 命令参数
+	Raw     string   // 命令名之后的原始参数文本
+	Choices []Choice // 选择支选项
+	Expr    Expr     // if/elseif 的条件或 @set 的右值
+	Next    int      // if/elseif/else：下一个分支节点的下标
+	End     int      // if/elseif/else：对应 endif 节点的下标
+}
+
+// Script 是一个编译好的 .rgo 脚本
+type Script struct {
+	Name     string
+	Nodes    []*Node
+	Labels   map[string]int // 标签名 -> 标签节点下标
+	Includes []Include      // @include 引入的模块
+	included []*Script      // 由 Registry 解析后的被引入模块
+}
+
+// Include 记录一条 @include 指令
+type Include struct {
+	Name string
+	Pos  Pos
+}
+
+// ParseError 带源码位置的脚本错误
+type ParseError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ParseErrors 一次解析中收集到的所有错误
+type ParseErrors []*ParseError
+
+func (el ParseErrors) Error() string {
+	msgs := make([]string, len(el))
+	for i, err := range el {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// commandSpec 描述命令的参数要求，maxArgs 为 -1 表示不限
+type commandSpec struct {
+	minArgs, maxArgs int
+	intArgs          []int                     // 必须是整数的参数下标
+	pathArgs         []int                     // 文件路径参数下标，供脚本检查使用
+	paths            func(args []string) []int // 路径参数下标随参数变化时使用，优先于 pathArgs
+	labelArgs        []int                     // 跳转目标参数下标，供脚本检查使用
+	validate         func(args []string) error
+}
+
+// commandSpecs 登记所有运行时支持的普通命令，不在表中的命令视为未知命令
+var commandSpecs = map[string]commandSpec{
+	"bg": {minArgs: 2, maxArgs: 4, intArgs: []int{0}, pathArgs: []int{1, 2}, validate: func(args []string) error {
+		if len(args) == 3 {
+			return fmt.Errorf("transition needs both a mask image and an effect name")
+		}
+		return nil
+	}},
+	"chara":     {minArgs: 1, maxArgs: -1, paths: charaPaths, validate: validateChara},
+	"hide":      {minArgs: 1, maxArgs: 1},
+	"trans":     {minArgs: 2, maxArgs: -1, paths: transPaths, validate: validateTrans},
+	"shake":     {minArgs: 1, maxArgs: 4, validate: cameraValidator("shake")},
+	"flash":     {minArgs: 0, maxArgs: 4, validate: cameraValidator("flash")},
+	"tint":      {minArgs: 1, maxArgs: 4, validate: cameraValidator("tint")},
+	"zoom":      {minArgs: 1, maxArgs: 6, validate: cameraValidator("zoom")},
+	"pan":       {minArgs: 2, maxArgs: 5, validate: cameraValidator("pan")},
+	"shader":    {minArgs: 1, maxArgs: -1, validate: validateShader},
+	"move":      {minArgs: 2, maxArgs: 5, validate: tweenValidator(2, checkSlot)},
+	"fadein":    {minArgs: 1, maxArgs: 4, validate: tweenValidator(1, nil)},
+	"fadeout":   {minArgs: 1, maxArgs: 4, validate: tweenValidator(1, nil)},
+	"scale":     {minArgs: 2, maxArgs: 5, validate: tweenValidator(2, checkFloat)},
+	"rotate":    {minArgs: 2, maxArgs: 5, validate: tweenValidator(2, checkFloat)},
+	"bounce":    {minArgs: 1, maxArgs: 4, validate: tweenValidator(1, nil)},
+	"affection": {minArgs: 2, maxArgs: 2, intArgs: []int{1}},
+	"jump":      {minArgs: 1, maxArgs: 1, labelArgs: []int{0}},
+	"clear":     {minArgs: 1, maxArgs: 1, intArgs: []int{0}},
+	"set":       {minArgs: 1, maxArgs: -1}, // 参数由 parseAssignment 解析
+	"call":      {minArgs: 1, maxArgs: 1, labelArgs: []int{0}},
+	"return":    {minArgs: 0, maxArgs: 0},
+	"include":   {minArgs: 1, maxArgs: 1},
+	"bgm": {minArgs: 1, maxArgs: -1, pathArgs: []int{0}, validate: optionValidator(1, map[string]func(string) error{
+		"fade": checkSeconds, "volume": checkFloat, "loopstart": checkSeconds, "loopend": checkSeconds,
+	})},
+	"stopbgm":   {minArgs: 0, maxArgs: 1, validate: optionValidator(0, map[string]func(string) error{"fade": checkSeconds})},
+	"se":        {minArgs: 1, maxArgs: 2, pathArgs: []int{0}, validate: optionValidator(1, map[string]func(string) error{"volume": checkFloat})},
+	"stopse":    {minArgs: 0, maxArgs: 0},
+	"voice":     {minArgs: 1, maxArgs: 2, pathArgs: []int{0}, validate: optionValidator(1, map[string]func(string) error{"volume": checkFloat})},
+	"stopvoice": {minArgs: 0, maxArgs: 0},
+	"preload":   {minArgs: 1, maxArgs: -1, paths: allArgs},
+}
+
+// checkArgs 按 commandSpecs 检查命令参数，未知命令不在这里报错
+func checkArgs(name string, args []string) error {
+	spec, ok := commandSpecs[name]
+	if !ok {
+		return nil
+	}
+	if len(args) < spec.minArgs || (spec.maxArgs >= 0 && len(args) > spec.maxArgs) {
+		switch {
+		case spec.minArgs == spec.maxArgs:
+			return fmt.Errorf("@%s expects %d argument(s), got %d", name, spec.minArgs, len(args))
+		case spec.maxArgs < 0:
+			return fmt.Errorf("@%s expects at least %d argument(s), got %d", name, spec.minArgs, len(args))
+		default:
+			return fmt.Errorf("@%s expects %d to %d arguments, got %d", name, spec.minArgs, spec.maxArgs, len(args))
+		}
+	}
+	for _, i := range spec.intArgs {
+		if i < len(args) {
+			if _, err := strconv.Atoi(args[i]); err != nil {
+				return fmt.Errorf("@%s argument %d must be an integer, got %q", name, i+1, args[i])
+			}
+		}
+	}
+	if spec.validate != nil {
+		if err := spec.validate(args); err != nil {
+			return fmt.Errorf("@%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// tokenize 按空白切分一行，双引号内的空白保留，支持 \" 和 \\ 转义
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inToken, inQuote, escaped := false, false, false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case inQuote && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// parseChoices 解析 "文本 -> 标签" 三元组
+func parseChoices(args []string) ([]Choice, error) {
+	if len(args) == 0 || len(args)%3 != 0 {
+		return nil, fmt.Errorf("@choice expects groups of \"text -> label\"")
+	}
+	choices := make([]Choice, 0, len(args)/3)
+	for i := 0; i < len(args); i += 3 {
+		if args[i+1] != "->" {
+			return nil, fmt.Errorf("@choice option %d is missing \"->\"", i/3+1)
+		}
+		choices = append(choices, Choice{Text: args[i], JumpTo: args[i+2]})
+	}
+	return choices, nil
+}
+
+// ParseOptions 把 key=value 形式的可选参数拆成映射
+func ParseOptions(args []string) (map[string]string, error) {
+	opts := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", arg)
+		}
+		opts[key] = value
+	}
+	return opts, nil
+}
+
+// ParseSeconds 解析时长，支持 "1.5s"、"500ms" 和不带单位的秒数
+func ParseSeconds(s string) (float64, error) {
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "ms"):
+		s, scale = strings.TrimSuffix(s, "ms"), 0.001
+	case strings.HasSuffix(s, "s"):
+		s = strings.TrimSuffix(s, "s")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return v * scale, nil
+}
+
+func checkInt(s string) error {
+	if _, err := strconv.Atoi(s); err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	return nil
+}
+
+// SplitOptions 把参数分成普通参数和 key=value 选项
+func SplitOptions(args []string) (plain, opts []string) {
+	for _, arg := range args {
+		if strings.Contains(arg, "=") {
+			opts = append(opts, arg)
+		} else {
+			plain = append(plain, arg)
+		}
+	}
+	return plain, opts
+}
+
+// allArgs 所有参数都是文件路径
+func allArgs(args []string) []int {
+	paths := make([]int, len(args))
+	for i := range paths {
+		paths[i] = i
+	}
+	return paths
+}
+
+// IsImageChara 第一个参数是图层编号时为单张图片立绘：@chara 图层 角色名 图片 [位置]，
+// 否则为组合立绘：@chara 角色名 部件... [at=位置] [layer=图层]
+func IsImageChara(args []string) bool {
+	_, err := strconv.Atoi(args[0])
+	return err == nil
+}
+
+func charaPaths(args []string) []int {
+	if IsImageChara(args) {
+		return []int{2}
+	}
+	return nil
+}
+
+func validateChara(args []string) error {
+	if IsImageChara(args) {
+		if len(args) > 4 {
+			return fmt.Errorf("expects a layer, a name, an image and an optional position, got %d arguments", len(args))
+		}
+		if len(args) < 3 {
+			return fmt.Errorf("missing image path")
+		}
+		return nil
+	}
+	if strings.Contains(args[0], "=") {
+		return fmt.Errorf("missing character name")
+	}
+	plain, opts := SplitOptions(args)
+	for _, arg := range args[:len(plain)] {
+		if strings.Contains(arg, "=") {
+			return fmt.Errorf("parts must come before the options")
+		}
+	}
+	return optionValidator(0, map[string]func(string) error{"at": checkSlot, "layer": checkInt})(opts)
+}
+
+func checkSlot(s string) error {
+	if s == "" {
+		return fmt.Errorf("empty position")
+	}
+	return nil
+}
+
+func checkSeconds(s string) error {
+	_, err := ParseSeconds(s)
+	return err
+}
+
+func checkFloat(s string) error {
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	return nil
+}
+
+// optionValidator 生成 commandSpec.validate：跳过前 skip 个位置参数，
+// 其余参数必须是 keys 中登记的 key=value，值交给对应的检查函数
+func optionValidator(skip int, keys map[string]func(string) error) func(args []string) error {
+	return func(args []string) error {
+		if len(args) < skip {
+			return nil
+		}
+		opts, err := ParseOptions(args[skip:])
+		if err != nil {
+			return err
+		}
+		for key, value := range opts {
+			check, ok := keys[key]
+			if !ok {
+				return fmt.Errorf("unknown option %q", key)
+			}
+			if err := check(value); err != nil {
+				return fmt.Errorf("option %s: %v", key, err)
+			}
+		}
+		return nil
+	}
+}
+
+// parseCondition 编译 @if/@elseif 的条件表达式
+func parseCondition(raw string) (Expr, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("missing condition")
+	}
+	return ParseExpr(raw)
+}
+
+// Parse 把脚本源码编译为节点列表，并预先建立标签索引和 if 块的分支关系
+func Parse(name string, r io.Reader) (*Script, error) {
+	script := &Script{
+		Name:   name,
+		Nodes:  make([]*Node, 0),
+		Labels: make(map[string]int),
+	}
+	var errs ParseErrors
+	fail := func(pos Pos, format string, args ...interface{}) {
+		errs = append(errs, &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	// 每个打开的 if 块记录其所有分支节点下标
+	var blocks [][]int
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue // 跳过空行和注释
+		}
+		pos := Pos{File: name, Line: lineNo}
+		index := len(script.Nodes)
+
+		switch {
+		case strings.HasPrefix(line, ":"):
+			label := strings.TrimSpace(line[1:])
+			if label == "" {
+				fail(pos, "empty label")
+				continue
+			}
+			if prev, ok := script.Labels[label]; ok {
+				fail(pos, "duplicate label %q (first defined at line %d)", label, script.Nodes[prev].Pos.Line)
+				continue
+			}
+			script.Labels[label] = index
+			script.Nodes = append(script.Nodes, &Node{Kind: NodeLabel, Pos: pos, Name: label})
+
+		case strings.HasPrefix(line, "@"):
+			body := strings.TrimSpace(line[1:])
+			cmd, raw := body, ""
+			if i := strings.IndexAny(body, " \t"); i >= 0 {
+				cmd, raw = body[:i], strings.TrimSpace(body[i+1:])
+			}
+			if cmd == "" {
+				fail(pos, "empty command")
+				continue
+			}
+			args, err := tokenize(raw)
+			if err != nil {
+				fail(pos, "@%s: %v", cmd, err)
+				continue
+			}
+			node := &Node{Kind: NodeCommand, Pos: pos, Name: cmd, Args: args, Raw: raw}
+
+			switch cmd {
+			case "if":
+				node.Kind = NodeIf
+				if node.Expr, err = parseCondition(raw); err != nil {
+					fail(pos, "@if: %v", err)
+				}
+				blocks = append(blocks, []int{index})
+			case "elseif", "else":
+				if cmd == "elseif" {
+					node.Kind = NodeElseIf
+					if node.Expr, err = parseCondition(raw); err != nil {
+						fail(pos, "@elseif: %v", err)
+					}
+				} else {
+					node.Kind = NodeElse
+				}
+				if len(blocks) == 0 {
+					fail(pos, "@%s without matching @if", cmd)
+					continue
+				}
+				top := blocks[len(blocks)-1]
+				if script.Nodes[top[len(top)-1]].Kind == NodeElse {
+					fail(pos, "@%s after @else", cmd)
+					continue
+				}
+				blocks[len(blocks)-1] = append(top, index)
+			case "endif":
+				node.Kind = NodeEndIf
+				if len(blocks) == 0 {
+					fail(pos, "@endif without matching @if")
+					continue
+				}
+				branches := blocks[len(blocks)-1]
+				blocks = blocks[:len(blocks)-1]
+				for i, b := range branches {
+					if i+1 < len(branches) {
+						script.Nodes[b].Next = branches[i+1]
+					} else {
+						script.Nodes[b].Next = index
+					}
+					script.Nodes[b].End = index
+				}
+			case "choice":
+				node.Kind = NodeChoice
+				choices, err := parseChoices(args)
+				if err != nil {
+					fail(pos, "%v", err)
+				}
+				node.Choices = choices
+			case "include":
+				// @include 是加载期指令，不生成可执行节点
+				if err := checkArgs(cmd, args); err != nil {
+					fail(pos, "%v", err)
+					continue
+				}
+				script.Includes = append(script.Includes, Include{Name: args[0], Pos: pos})
+				continue
+			case "set":
+				name, op, expr, err := parseAssignment(raw)
+				if err != nil {
+					fail(pos, "@set: %v", err)
+				}
+				node.Args = []string{name, op}
+				node.Expr = expr
+			default:
+				if err := checkArgs(cmd, args); err != nil {
+					fail(pos, "%v", err)
+				}
+			}
+			script.Nodes = append(script.Nodes, node)
+
+		default:
+			script.Nodes = append(script.Nodes, &Node{Kind: NodeText, Pos: pos, Text: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read script file: %v", err)
+	}
+
+	for _, branches := range blocks {
+		fail(script.Nodes[branches[0]].Pos, "@if without matching @endif")
+	}
+	if len(errs) > 0 {
+		return script, errs
+	}
+	return script, nil
+}
@@ -0,0 +1,173 @@
+package script
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func parseString(t *testing.T, src string) *Script {
+	t.Helper()
+	s, err := Parse("test.rgo", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return s
+}
+
+func TestParseNestedIf(t *testing.T) {
+	s := parseString(t, `@if a
+A
+@if b
+B
+@else
+C
+@endif
+@elseif c
+D
+@else
+E
+@endif
+`)
+	// 每个分支节点的 Next 指向下一个分支，End 指向对应的 endif
+	tests := []struct {
+		index     int
+		kind      NodeKind
+		next, end int
+	}{
+		{0, NodeIf, 7, 11},
+		{2, NodeIf, 4, 6},
+		{4, NodeElse, 6, 6},
+		{7, NodeElseIf, 9, 11},
+		{9, NodeElse, 11, 11},
+	}
+	if len(s.Nodes) != 12 {
+		t.Fatalf("got %d nodes, want 12", len(s.Nodes))
+	}
+	for _, tt := range tests {
+		node := s.Nodes[tt.index]
+		if node.Kind != tt.kind || node.Next != tt.next || node.End != tt.end {
+			t.Errorf("node %d: %s next=%d end=%d, want %s next=%d end=%d",
+				tt.index, node.Kind, node.Next, node.End, tt.kind, tt.next, tt.end)
+		}
+	}
+	for _, index := range []int{6, 11} {
+		if s.Nodes[index].Kind != NodeEndIf {
+			t.Errorf("node %d is %s, want endif", index, s.Nodes[index].Kind)
+		}
+	}
+}
+
+func TestParseBlockErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+		msg  string
+	}{
+		{"unterminated if", "@if a\nA\n", 1, "@if without matching @endif"},
+		{"unterminated nested if", "@if a\n@if b\n@endif\n", 1, "@if without matching @endif"},
+		{"else after else", "@if a\n@else\n@else\n@endif\n", 3, "@else after @else"},
+		{"elseif after else", "@if a\n@else\n@elseif b\n@endif\n", 3, "@elseif after @else"},
+		{"stray endif", "A\n@endif\n", 2, "@endif without matching @if"},
+		{"stray elseif", "@elseif a\n", 1, "@elseif without matching @if"},
+		{"stray else", "-- comment\n@else\n", 2, "@else without matching @if"},
+		{"missing condition", "@if\n@endif\n", 1, "@if: missing condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test.rgo", strings.NewReader(tt.src))
+			errs, ok := err.(ParseErrors)
+			if !ok || len(errs) != 1 {
+				t.Fatalf("got error %v, want one parse error", err)
+			}
+			if errs[0].Pos.Line != tt.line || !strings.Contains(errs[0].Msg, tt.msg) {
+				t.Errorf("got %q at line %d, want %q at line %d", errs[0].Msg, errs[0].Pos.Line, tt.msg, tt.line)
+			}
+		})
+	}
+}
+
+func TestRegistryIncludeCycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.rgo":      {Data: []byte("@include b.rgo\n:start\n@jump shared\n")},
+		"b.rgo":      {Data: []byte("@include a.rgo\n:shared\nShared.\n")},
+		"self.rgo":   {Data: []byte("@include self.rgo\n:loop\n")},
+		"broken.rgo": {Data: []byte("Hello.\n@include missing.rgo\n")},
+	}
+	r := NewRegistryFS(fsys, "script")
+
+	a, err := r.Get("a.rgo")
+	if err != nil {
+		t.Fatalf("Get(a.rgo): %v", err)
+	}
+	b, err := r.Get("b.rgo")
+	if err != nil {
+		t.Fatalf("Get(b.rgo): %v", err)
+	}
+	tests := []struct {
+		from   *Script
+		target string
+		want   string
+	}{
+		{a, "shared", "b.rgo"},
+		{b, "start", "a.rgo"},
+		{a, "start", "a.rgo"},
+		{b, "a.rgo:start", "a.rgo"},
+	}
+	for _, tt := range tests {
+		found, index, err := r.Resolve(tt.from, tt.target)
+		if err != nil {
+			t.Errorf("Resolve(%s, %s): %v", tt.from.Name, tt.target, err)
+			continue
+		}
+		if found.Name != tt.want || found.Nodes[index].Name != strings.TrimPrefix(tt.target, "a.rgo:") {
+			t.Errorf("Resolve(%s, %s) = %s node %d, want label in %s", tt.from.Name, tt.target, found.Name, index, tt.want)
+		}
+	}
+	// 互相引入的模块中找不到的标签必须正常报错而不是无限递归
+	if _, _, err := r.Resolve(a, "missing"); err == nil {
+		t.Error("Resolve of a missing label succeeded")
+	}
+
+	self, err := r.Get("self.rgo")
+	if err != nil {
+		t.Fatalf("Get(self.rgo): %v", err)
+	}
+	if _, _, err := r.Resolve(self, "nowhere"); err == nil {
+		t.Error("Resolve of a missing label in a self-including module succeeded")
+	}
+
+	_, err = r.Get("broken.rgo")
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 1 || errs[0].Pos.Line != 2 {
+		t.Errorf("Get(broken.rgo) = %v, want one error at the @include line", err)
+	}
+}
+
+func TestRegistryBrokenInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.rgo": {Data: []byte("@include lib.rgo\n:start\n@jump helper\n")},
+		"lib.rgo":  {Data: []byte(":helper\n@bg\n@set x =\n")},
+	}
+	r := NewRegistryFS(fsys, "script")
+
+	// 被引入的模块有错误时，引入它的模块也要在 @include 行报错
+	main, err := r.Get("main.rgo")
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 1 || errs[0].Pos.Line != 1 || !strings.Contains(errs[0].Msg, "@include lib.rgo") {
+		t.Fatalf("Get(main.rgo) = %v, want one error at the @include line", err)
+	}
+	// 部分结果仍然可以查找被引入模块中的标签
+	if found, _, err := r.Resolve(main, "helper"); err != nil || found.Name != "lib.rgo" {
+		t.Errorf("Resolve(main.rgo, helper) = %v, %v", found, err)
+	}
+
+	_, err = r.Get("lib.rgo")
+	if errs, ok := err.(ParseErrors); !ok || len(errs) != 2 {
+		t.Errorf("Get(lib.rgo) = %v, want two errors", err)
+	}
+	if problems := r.Check("main.rgo", func(s string) string { return s }); len(problems) != 1 {
+		t.Errorf("Check(main.rgo) = %v, want one problem", problems)
+	}
+}
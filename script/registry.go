@@ -0,0 +1,162 @@
+package script
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// scriptEntry 缓存一个模块的编译结果，编译出错时也保留部分结果供检查工具使用
+type scriptEntry struct {
+	script *Script
+	err    error
+}
+
+// Registry 管理脚本目录下的所有模块，每个 .rgo 文件是一个独立模块，
+// 模块名是相对脚本根目录的路径，例如 "chapter1/school.rgo"
+type Registry struct {
+	root    string
+	fsys    fs.FS
+	modules map[string]*scriptEntry
+}
+
+// NewRegistry 读取磁盘上的脚本目录
+func NewRegistry(root string) *Registry {
+	return NewRegistryFS(os.DirFS(root), root)
+}
+
+// NewRegistryFS 从文件系统读取脚本，例如打包文件中的脚本目录，root 只用于错误信息
+func NewRegistryFS(fsys fs.FS, root string) *Registry {
+	return &Registry{
+		root:    root,
+		fsys:    fsys,
+		modules: make(map[string]*scriptEntry),
+	}
+}
+
+func (r *Registry) Root() string {
+	return r.root
+}
+
+// ModuleName 把文件路径规范化为模块名，兼容带脚本根目录前缀的旧路径
+func (r *Registry) ModuleName(path string) string {
+	name := filepath.Clean(path)
+	if rel, err := filepath.Rel(filepath.Clean(r.root), name); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+	return filepath.ToSlash(name)
+}
+
+// Modules 列出脚本根目录下所有模块名，按名称排序
+func (r *Registry) Modules() ([]string, error) {
+	var names []string
+	err := fs.WalkDir(r.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".rgo") {
+			names = append(names, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %v", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get 返回编译好的模块，首次访问时加载并解析它的 @include
+func (r *Registry) Get(name string) (*Script, error) {
+	name = r.ModuleName(name)
+	if entry, ok := r.modules[name]; ok {
+		return entry.script, entry.err
+	}
+	path := filepath.Join(r.root, filepath.FromSlash(name))
+	file, err := r.fsys.Open(name)
+	if err != nil {
+		err = fmt.Errorf("failed to read script file: %v", err)
+		r.modules[name] = &scriptEntry{err: err}
+		return nil, err
+	}
+	script, err := Parse(path, file)
+	file.Close()
+	if script == nil {
+		r.modules[name] = &scriptEntry{err: err}
+		return nil, err
+	}
+	script.Name = name
+
+	// 先登记再解析 @include，模块互相引入时直接拿到已登记的模块
+	entry := &scriptEntry{script: script, err: err}
+	r.modules[name] = entry
+
+	// 解析 @include，错误归到 include 所在的行
+	errs, _ := err.(ParseErrors)
+	for _, inc := range script.Includes {
+		included, incErr := r.Get(inc.Name)
+		// 被引入的模块有错误时本模块也不能运行，模块本身的错误在检查该模块时逐条报告
+		if incErr != nil {
+			msg := fmt.Sprintf("@include %s: %v", inc.Name, incErr)
+			if incErrs, ok := incErr.(ParseErrors); ok {
+				msg = fmt.Sprintf("@include %s: included script has %d error(s)", inc.Name, len(incErrs))
+			}
+			errs = append(errs, &ParseError{Pos: inc.Pos, Msg: msg})
+		}
+		if included != nil {
+			script.included = append(script.included, included)
+		}
+	}
+	if len(errs) > 0 {
+		entry.err = errs
+	}
+	return script, entry.err
+}
+
+// Resolve 查找跳转目标，返回目标所在模块和标签节点下标。
+// 不带文件名的标签先在当前模块查找，再依次查找被 @include 的模块
+func (r *Registry) Resolve(from *Script, target string) (*Script, int, error) {
+	file, label := splitTarget(target)
+	script := from
+	if file != "" {
+		other, err := r.Get(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		script = other
+	}
+	if script == nil {
+		return nil, 0, fmt.Errorf("no script loaded")
+	}
+	if found, index, ok := script.findLabel(label, make(map[*Script]bool)); ok {
+		return found, index, nil
+	}
+	return nil, 0, fmt.Errorf("label %q not found", target)
+}
+
+func (s *Script) findLabel(label string, seen map[*Script]bool) (*Script, int, bool) {
+	if seen[s] {
+		return nil, 0, false
+	}
+	seen[s] = true
+	if index, ok := s.Labels[label]; ok {
+		return s, index, true
+	}
+	for _, inc := range s.included {
+		if found, index, ok := inc.findLabel(label, seen); ok {
+			return found, index, true
+		}
+	}
+	return nil, 0, false
+}
+
+// splitTarget 拆分跳转目标，"chapter2.rgo:start" 指向其他模块中的标签
+func splitTarget(target string) (file, label string) {
+	if i := strings.LastIndex(target, ".rgo:"); i >= 0 {
+		return target[:i+len(".rgo")], target[i+len(".rgo:"):]
+	}
+	return "", target
+}
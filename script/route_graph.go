@@ -0,0 +1,212 @@
+package script
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RouteNode 剧情图中的一个标签，Label 为空表示模块开头到第一个标签之间的部分
+type RouteNode struct {
+	ID      string `json:"id"`
+	Module  string `json:"module"`
+	Label   string `json:"label"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Missing bool   `json:"missing,omitempty"` // 被引用但找不到的目标
+}
+
+// RouteEdge 剧情图中的一条跳转，Kind 为 jump、call、choice 或 next（顺序执行进入下一个标签）
+type RouteEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Kind      string `json:"kind"`
+	Text      string `json:"text,omitempty"`      // 选项文字
+	Condition string `json:"condition,omitempty"` // 所在 @if 分支的条件
+}
+
+// RouteGraph 由脚本生成的剧情流程图
+type RouteGraph struct {
+	Nodes []RouteNode `json:"nodes"`
+	Edges []RouteEdge `json:"edges"`
+}
+
+func routeNodeID(module, label string) string {
+	return module + ":" + label
+}
+
+// BuildRouteGraph 读取模块，把标签作为节点，把 @jump、@call、@choice 和顺序执行作为边
+func BuildRouteGraph(registry *Registry, modules []string) (*RouteGraph, error) {
+	g := &RouteGraph{}
+	known := make(map[string]bool)
+	addNode := func(n RouteNode) {
+		if !known[n.ID] {
+			known[n.ID] = true
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+
+	for _, name := range modules {
+		// 有语法错误的模块仍使用已解析的部分，错误交给 rengo-check 报告
+		script, err := registry.Get(name)
+		if script == nil {
+			return nil, err
+		}
+
+		// 先登记本模块的全部标签，保证节点顺序与源文件一致
+		if len(script.Nodes) > 0 && script.Nodes[0].Kind != NodeLabel {
+			addNode(RouteNode{ID: routeNodeID(script.Name, ""), Module: script.Name, File: script.Nodes[0].Pos.File, Line: script.Nodes[0].Pos.Line})
+		}
+		for _, node := range script.Nodes {
+			if node.Kind == NodeLabel {
+				addNode(RouteNode{ID: routeNodeID(script.Name, node.Name), Module: script.Name, Label: node.Name, File: node.Pos.File, Line: node.Pos.Line})
+			}
+		}
+
+		current := routeNodeID(script.Name, "")
+		terminated := false // 当前位置之前有无条件跳转，不会顺序执行到这里
+		var conditions []string
+
+		target := func(t string) string {
+			found, index, err := registry.Resolve(script, t)
+			if err == nil {
+				return routeNodeID(found.Name, found.Nodes[index].Name)
+			}
+			// 找不到的目标归到引用它的模块，不同模块中同名的缺失标签不会合并成一个节点
+			file, label := splitTarget(t)
+			module := script.Name
+			if file != "" {
+				module = registry.ModuleName(file)
+			}
+			id := routeNodeID(module, label)
+			addNode(RouteNode{ID: id, Module: module, Label: label, Missing: true})
+			return id
+		}
+		addEdge := func(kind, to, text string) {
+			g.Edges = append(g.Edges, RouteEdge{
+				From:      current,
+				To:        to,
+				Kind:      kind,
+				Text:      text,
+				Condition: strings.Join(conditions, " && "),
+			})
+		}
+
+		for _, node := range script.Nodes {
+			switch node.Kind {
+			case NodeLabel:
+				id := routeNodeID(script.Name, node.Name)
+				if !terminated && known[current] {
+					addEdge("next", id, "")
+				}
+				current = id
+				terminated = false
+			case NodeIf:
+				conditions = append(conditions, node.Raw)
+			case NodeElseIf:
+				if len(conditions) > 0 {
+					conditions[len(conditions)-1] = node.Raw
+				}
+			case NodeElse:
+				if len(conditions) > 0 {
+					conditions[len(conditions)-1] = "else"
+				}
+			case NodeEndIf:
+				if len(conditions) > 0 {
+					conditions = conditions[:len(conditions)-1]
+				}
+			case NodeChoice:
+				for _, c := range node.Choices {
+					addEdge("choice", target(c.JumpTo), c.Text)
+				}
+				terminated = terminated || len(conditions) == 0
+			case NodeCommand:
+				// 参数检查失败的命令也留在模块中，缺少目标时跳过，错误交给 rengo-check 报告
+				if (node.Name == "jump" || node.Name == "call") && len(node.Args) == 0 {
+					continue
+				}
+				switch node.Name {
+				case "jump":
+					addEdge("jump", target(node.Args[0]), "")
+					terminated = terminated || len(conditions) == 0
+				case "call":
+					addEdge("call", target(node.Args[0]), "")
+				case "return":
+					terminated = terminated || len(conditions) == 0
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// WriteJSON 以 JSON 格式输出剧情图
+func (g *RouteGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// WriteDOT 以 Graphviz DOT 格式输出剧情图，每个模块一个子图
+func (g *RouteGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph routes {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=box];")
+
+	var modules []string
+	byModule := make(map[string][]RouteNode)
+	for _, n := range g.Nodes {
+		if n.Missing {
+			fmt.Fprintf(bw, "  %s [label=%s, color=red, style=dashed];\n", dotQuote(n.ID), dotQuote(n.ID))
+			continue
+		}
+		if _, ok := byModule[n.Module]; !ok {
+			modules = append(modules, n.Module)
+		}
+		byModule[n.Module] = append(byModule[n.Module], n)
+	}
+	for _, m := range modules {
+		fmt.Fprintf(bw, "  subgraph %s {\n", dotQuote("cluster_"+m))
+		fmt.Fprintf(bw, "    label=%s;\n", dotQuote(m))
+		for _, n := range byModule[m] {
+			label := n.Label
+			if label == "" {
+				label = "(start)"
+			}
+			fmt.Fprintf(bw, "    %s [label=%s];\n", dotQuote(n.ID), dotQuote(label))
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+
+	for _, e := range g.Edges {
+		var parts []string
+		if e.Text != "" {
+			parts = append(parts, e.Text)
+		}
+		if e.Condition != "" {
+			parts = append(parts, "["+e.Condition+"]")
+		}
+		attrs := []string{"label=" + dotQuote(strings.Join(parts, "\n"))}
+		switch e.Kind {
+		case "call":
+			attrs = append(attrs, "style=dashed")
+		case "next":
+			attrs = append(attrs, "style=dotted")
+		case "choice":
+			attrs = append(attrs, "style=bold")
+		}
+		fmt.Fprintf(bw, "  %s -> %s [%s];\n", dotQuote(e.From), dotQuote(e.To), strings.Join(attrs, ", "))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
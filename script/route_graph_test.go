@@ -0,0 +1,107 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func buildTestGraph(t *testing.T) *RouteGraph {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"main.rgo": {Data: []byte(`Intro.
+@choice "Go" -> side.rgo:start "Stay" -> stay
+:stay
+@if met
+@jump side.rgo:start
+@endif
+@jump nowhere
+`)},
+		"side.rgo": {Data: []byte(`:start
+Side.
+:second
+@call main.rgo:stay
+@jump nowhere
+`)},
+	}
+	g, err := BuildRouteGraph(NewRegistryFS(fsys, "script"), []string{"main.rgo", "side.rgo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestBuildRouteGraph(t *testing.T) {
+	g := buildTestGraph(t)
+
+	var ids []string
+	missing := make(map[string]bool)
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+		missing[n.ID] = n.Missing
+	}
+	// 两个模块中缺失的同名标签是不同的节点
+	wantIDs := []string{"main.rgo:", "main.rgo:stay", "main.rgo:nowhere", "side.rgo:start", "side.rgo:second", "side.rgo:nowhere"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("nodes %q, want %q", ids, wantIDs)
+	}
+	for _, id := range []string{"main.rgo:nowhere", "side.rgo:nowhere"} {
+		if !missing[id] {
+			t.Errorf("node %s is not marked missing", id)
+		}
+	}
+
+	wantEdges := []RouteEdge{
+		{From: "main.rgo:", To: "side.rgo:start", Kind: "choice", Text: "Go"},
+		{From: "main.rgo:", To: "main.rgo:stay", Kind: "choice", Text: "Stay"},
+		{From: "main.rgo:stay", To: "side.rgo:start", Kind: "jump", Condition: "met"},
+		{From: "main.rgo:stay", To: "main.rgo:nowhere", Kind: "jump"},
+		{From: "side.rgo:start", To: "side.rgo:second", Kind: "next"},
+		{From: "side.rgo:second", To: "main.rgo:stay", Kind: "call"},
+		{From: "side.rgo:second", To: "side.rgo:nowhere", Kind: "jump"},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges:\n%+v\nwant:\n%+v", g.Edges, wantEdges)
+	}
+}
+
+func TestRouteGraphOutput(t *testing.T) {
+	g := buildTestGraph(t)
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded RouteGraph
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, g) {
+		t.Errorf("JSON round trip:\n%+v\nwant:\n%+v", decoded, *g)
+	}
+
+	buf.Reset()
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	for _, want := range []string{
+		`subgraph "cluster_main.rgo" {`,
+		`"main.rgo:" [label="(start)"];`,
+		`"side.rgo:nowhere" [label="side.rgo:nowhere", color=red, style=dashed];`,
+		`"main.rgo:" -> "side.rgo:start" [label="Go", style=bold];`,
+		`"main.rgo:stay" -> "side.rgo:start" [label="[met]"];`,
+		`"side.rgo:start" -> "side.rgo:second" [label="", style=dotted];`,
+		`"side.rgo:second" -> "main.rgo:stay" [label="", style=dashed];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output is missing %s\n%s", want, dot)
+		}
+	}
+	if !strings.HasPrefix(dot, "digraph routes {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("DOT output is not a digraph:\n%s", dot)
+	}
+}
@@ -0,0 +1,72 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ShaderCommand 解析后的 @shader 命令：
+//
+//	@shader 名称 [layer=图层] [参数=值...] [时长] [缓动] [wait]
+//	@shader 名称 off [layer=图层]
+//	@shader none [layer=图层]
+//
+// 向量参数用逗号分隔各分量，例如 color=1,0.8,0.6
+type ShaderCommand struct {
+	Name     string
+	Target   string
+	Off      bool
+	Uniforms map[string][]float64
+	TweenArgs
+}
+
+// ParseShaderCommand 解析 @shader 命令的参数
+func ParseShaderCommand(args []string) (*ShaderCommand, error) {
+	plain, options := SplitOptions(args)
+	if len(plain) == 0 {
+		return nil, fmt.Errorf("missing shader name")
+	}
+	cmd := &ShaderCommand{Name: plain[0], Target: ScreenTarget, Uniforms: make(map[string][]float64)}
+	rest := plain[1:]
+	if len(rest) > 0 && rest[0] == "off" {
+		cmd.Off, rest = true, rest[1:]
+	}
+	ta, err := ParseTweenArgs(rest, 0)
+	if err != nil {
+		return nil, err
+	}
+	cmd.TweenArgs = ta
+
+	opts, err := ParseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range opts {
+		if key == "layer" {
+			if err := checkInt(value); err != nil {
+				return nil, err
+			}
+			cmd.Target = value
+			continue
+		}
+		var v []float64
+		for _, s := range strings.Split(value, ",") {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %q", key, value)
+			}
+			v = append(v, f)
+		}
+		cmd.Uniforms[key] = v
+	}
+	if (cmd.Off || cmd.Name == "none") && (len(cmd.Uniforms) > 0 || len(rest) > 0) {
+		return nil, fmt.Errorf("removing a shader takes no parameters")
+	}
+	return cmd, nil
+}
+
+func validateShader(args []string) error {
+	_, err := ParseShaderCommand(args)
+	return err
+}
@@ -0,0 +1,169 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ScreenTarget              = "screen" // @trans、@shader 的目标为整个画面
+	defaultTransitionDuration = 0.5
+)
+
+// transitionKind 一种内置转场，options 是可用的选项和默认值
+type transitionKind struct {
+	shader  string
+	options map[string]string
+	push    bool // slide 着色器同时推出旧画面
+}
+
+var transitionKinds = map[string]transitionKind{
+	"crossfade": {shader: "crossfade"},
+	"fade":      {shader: "fade", options: map[string]string{"color": "black"}},
+	"wipe":      {shader: "wipe", options: map[string]string{"dir": "right", "vague": "0.05"}},
+	"slide":     {shader: "slide", options: map[string]string{"dir": "right"}},
+	"push":      {shader: "slide", options: map[string]string{"dir": "right"}, push: true},
+	"dissolve":  {shader: "dissolve", options: map[string]string{"block": "4", "vague": "0"}},
+	"iris":      {shader: "iris", options: map[string]string{"dir": "out", "vague": "0.05"}},
+	"mask":      {shader: "mask", options: map[string]string{"mask": "", "vague": "0.1"}},
+}
+
+var directions = map[string][]float32{
+	"right": {1, 0},
+	"left":  {-1, 0},
+	"down":  {0, 1},
+	"up":    {0, -1},
+}
+
+// TransitionSpec 解析后的 @trans 命令：@trans 目标 类型 [时长] [缓动] [选项=值...]。
+// Shader 是内置着色器名，Uniforms 是由选项换算出的着色器参数
+type TransitionSpec struct {
+	Target   string
+	Shader   string
+	Mask     string
+	Uniforms map[string]any
+	TweenArgs
+}
+
+// ParseTransition 解析 @trans 命令的参数
+func ParseTransition(args []string) (*TransitionSpec, error) {
+	plain, options := SplitOptions(args)
+	if len(plain) < 2 {
+		return nil, fmt.Errorf("expected a target and a transition type")
+	}
+	kind, ok := transitionKinds[plain[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown transition %q", plain[1])
+	}
+	ta, err := ParseTweenArgs(plain[2:], defaultTransitionDuration)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := ParseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	for key := range opts {
+		if _, ok := kind.options[key]; !ok {
+			return nil, fmt.Errorf("transition %s has no option %q", plain[1], key)
+		}
+	}
+
+	spec := &TransitionSpec{Target: plain[0], Shader: kind.shader, Uniforms: make(map[string]any), TweenArgs: ta}
+	if kind.shader == "slide" {
+		spec.Uniforms["Push"] = float32(0)
+		if kind.push {
+			spec.Uniforms["Push"] = float32(1)
+		}
+	}
+	for key, value := range kind.options {
+		if v, ok := opts[key]; ok {
+			value = v
+		}
+		if err := spec.setOption(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// setOption 把选项转换为着色器参数
+func (s *TransitionSpec) setOption(key, value string) error {
+	switch key {
+	case "color":
+		c, err := parseColor(value)
+		if err != nil {
+			return err
+		}
+		s.Uniforms["Color"] = c
+	case "dir":
+		if s.Shader == "iris" {
+			switch value {
+			case "out":
+				s.Uniforms["Invert"] = float32(0)
+			case "in":
+				s.Uniforms["Invert"] = float32(1)
+			default:
+				return fmt.Errorf("iris direction must be in or out, got %q", value)
+			}
+			return nil
+		}
+		dir, ok := directions[value]
+		if !ok {
+			return fmt.Errorf("direction must be left, right, up or down, got %q", value)
+		}
+		s.Uniforms["Direction"] = dir
+	case "vague":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > 1 {
+			return fmt.Errorf("vague must be between 0 and 1, got %q", value)
+		}
+		s.Uniforms["Vague"] = float32(v)
+	case "block":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 1 {
+			return fmt.Errorf("block must be at least 1 pixel, got %q", value)
+		}
+		s.Uniforms["Block"] = float32(v)
+	case "mask":
+		if value == "" {
+			return fmt.Errorf("mask transition needs mask=<image>")
+		}
+		s.Mask = value
+	}
+	return nil
+}
+
+// parseColor 解析 black、white、red 或 #rrggbb，返回着色器使用的 RGBA
+func parseColor(s string) ([]float32, error) {
+	switch s {
+	case "black":
+		return []float32{0, 0, 0, 1}, nil
+	case "white":
+		return []float32{1, 1, 1, 1}, nil
+	case "red":
+		return []float32{1, 0, 0, 1}, nil
+	}
+	if len(s) == 7 && s[0] == '#' {
+		if v, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+			return []float32{float32(v>>16&0xff) / 255, float32(v>>8&0xff) / 255, float32(v&0xff) / 255, 1}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid color %q", s)
+}
+
+func validateTrans(args []string) error {
+	_, err := ParseTransition(args)
+	return err
+}
+
+// transPaths 返回 mask= 选项的下标
+func transPaths(args []string) []int {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "mask=") {
+			return []int{i}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package script
+
+import (
+	"fmt"
+)
+
+// Easing 把线性进度 0..1 映射为动画进度
+type Easing func(t float64) float64
+
+// Easings 脚本中可用的缓动函数
+var Easings = map[string]Easing{
+	"linear":      func(t float64) float64 { return t },
+	"ease-in":     func(t float64) float64 { return t * t },
+	"ease-out":    func(t float64) float64 { return t * (2 - t) },
+	"ease-in-out": easeInOut,
+	"back-out": func(t float64) float64 {
+		const s = 1.70158
+		t--
+		return t*t*((s+1)*t+s) + 1
+	},
+	"bounce-out": bounceOut,
+}
+
+const defaultEasing = "ease-in-out"
+
+func easeInOut(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+func bounceOut(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+// TweenArgs 动画命令末尾的可选参数：时长、缓动函数名和 wait，顺序不限
+type TweenArgs struct {
+	Duration float64
+	Ease     Easing
+	Wait     bool
+}
+
+// ParseTweenArgs 解析动画参数，没写时长时使用 duration
+func ParseTweenArgs(args []string, duration float64) (TweenArgs, error) {
+	ta := TweenArgs{Duration: duration, Ease: Easings[defaultEasing]}
+	for _, arg := range args {
+		if arg == "wait" {
+			ta.Wait = true
+		} else if ease, ok := Easings[arg]; ok {
+			ta.Ease = ease
+		} else if d, err := ParseSeconds(arg); err == nil {
+			ta.Duration = d
+		} else {
+			return ta, fmt.Errorf("expected a duration, an easing or wait, got %q", arg)
+		}
+	}
+	return ta, nil
+}
+
+// tweenValidator 生成动画命令的参数检查，skip 是动画参数之前的位置参数个数，
+// value 不为 nil 时检查目标之后的第一个参数
+func tweenValidator(skip int, value func(string) error) func(args []string) error {
+	return func(args []string) error {
+		if value != nil && len(args) > 1 {
+			if err := value(args[1]); err != nil {
+				return err
+			}
+		}
+		if len(args) <= skip {
+			return nil
+		}
+		_, err := ParseTweenArgs(args[skip:], 0)
+		return err
+	}
+}